@@ -0,0 +1,188 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sgo-api/base"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	redactedValue = "***"
+)
+
+type AuditRoute struct {
+	Method string // 请求方法，为空时匹配 AuditConfig.Methods 中的所有方法
+	Path   string // 路由模板（c.FullPath()），以 * 结尾时按前缀匹配
+}
+
+type AuditConfig struct {
+	Logger *base.AuditLogger
+
+	Methods []string     // 需要审计的方法。默认为 POST、PUT、PATCH、DELETE
+	Routes  []AuditRoute // 需要审计的路由。为空时审计所有路由
+
+	RedactFields    []string // 需要脱敏的字段名（JSON 和表单，不区分大小写）。默认为 password、token、secret 等
+	MaxBodySize     int      // 记录的请求体、响应体上限（字节），超出时截断。默认为 64KB
+	IncludeResponse bool     // 记录响应体，需要放在 LogMiddleware 之后
+
+	// 写入审计记录的超时时间。审计日志器串行写入，存储变慢或 ChanAuditSink 的消费方停止时，超时后放弃本条记录，避免阻塞所有请求。默认为 5s
+	WriteTimeout time.Duration
+
+	Skip func(c *gin.Context) bool
+}
+
+// 审计中间件，请求结束后把谁在什么时候对什么做了什么写入审计日志
+//   - 需要放在 LogMiddleware、AuthMiddleware 之后，才能取到 Trace ID、认证主体和响应
+//   - 写入失败只记录日志，不影响响应
+func AuditMiddleware(log base.Logger, conf AuditConfig) gin.HandlerFunc {
+	log = log.WithTag("AUDIT")
+
+	if conf.WriteTimeout <= 0 {
+		conf.WriteTimeout = 5 * time.Second
+	}
+	if len(conf.Methods) == 0 {
+		conf.Methods = []string{http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete}
+	}
+	if len(conf.RedactFields) == 0 {
+		conf.RedactFields = []string{
+			"password", "passwd", "secret", "token", "access_token", "refresh_token",
+			"authorization", "api_key", "apikey", "credential", "private_key",
+		}
+	}
+	if conf.MaxBodySize <= 0 {
+		conf.MaxBodySize = 64 << 10
+	}
+
+	methods := map[string]bool{}
+	for _, m := range conf.Methods {
+		methods[strings.ToUpper(m)] = true
+	}
+	redactFields := map[string]bool{}
+	for _, f := range conf.RedactFields {
+		redactFields[strings.ToLower(f)] = true
+	}
+
+	match := func(c *gin.Context) bool {
+		method := c.Request.Method
+		if !methods[method] {
+			return false
+		}
+		if len(conf.Routes) == 0 {
+			return true
+		}
+		for _, r := range conf.Routes {
+			if matchRoute(r.Method, r.Path, method, c.FullPath()) {
+				return true
+			}
+		}
+		return false
+	}
+
+	return func(c *gin.Context) {
+		if conf.Logger == nil || !match(c) || (conf.Skip != nil && conf.Skip(c)) {
+			c.Next()
+			return
+		}
+
+		c.Next()
+
+		req := GetRequest(c)
+		record := base.AuditRecord{
+			TraceID: GetTraceID(c),
+			IP:      req.IP,
+			Method:  req.Method,
+			Route:   req.Route,
+			Path:    req.Path,
+			Params:  map[string]string{},
+			Status:  c.Writer.Status(),
+		}
+		if p := GetPrincipal(c); p != nil {
+			record.PrincipalID = p.ID
+			record.PrincipalName = p.Name
+			record.PrincipalType = p.Type
+		}
+		for _, p := range c.Params {
+			record.Params[p.Key] = p.Value
+		}
+		if body, err := req.Body(); err == nil {
+			record.Body = redactBody(req.ContentType, body, redactFields, conf.MaxBodySize)
+		}
+		if conf.IncludeResponse {
+			if w, ok := c.Writer.(*Writer); ok {
+				record.Response = redactBody(gin.MIMEJSON, []byte(w.String()), redactFields, conf.MaxBodySize)
+			}
+		}
+
+		ctx, cancel := context.WithTimeout(context.WithoutCancel(c.Request.Context()), conf.WriteTimeout)
+		defer cancel()
+		if _, err := conf.Logger.Write(ctx, record); err != nil {
+			getLogger(c, log).Errorf("写入审计记录失败：%+v", err)
+		}
+	}
+}
+
+// 脱敏并截断请求体，只保留 JSON、表单和文本内容
+func redactBody(contentType string, body []byte, fields map[string]bool, limit int) string {
+	if len(body) == 0 {
+		return ""
+	}
+
+	switch contentType {
+	case gin.MIMEJSON:
+		d := json.NewDecoder(bytes.NewReader(body))
+		d.UseNumber()
+		var v any
+		if err := d.Decode(&v); err == nil {
+			if data, err := json.Marshal(redactJSON(v, fields)); err == nil {
+				body = data
+			}
+		}
+	case gin.MIMEPOSTForm:
+		if values, err := url.ParseQuery(string(body)); err == nil {
+			for k := range values {
+				if fields[strings.ToLower(k)] {
+					values[k] = []string{redactedValue}
+				}
+			}
+			body = []byte(values.Encode())
+		}
+	case gin.MIMEMultipartPOSTForm:
+		return fmt.Sprintf("[multipart %d bytes]", len(body))
+	default:
+		if !utf8.Valid(body) {
+			return fmt.Sprintf("[binary %d bytes]", len(body))
+		}
+	}
+
+	if len(body) > limit {
+		return string(body[:limit]) + "...(truncated)"
+	}
+	return string(body)
+}
+
+func redactJSON(v any, fields map[string]bool) any {
+	switch v := v.(type) {
+	case map[string]any:
+		for k, value := range v {
+			if fields[strings.ToLower(k)] {
+				v[k] = redactedValue
+			} else {
+				v[k] = redactJSON(value, fields)
+			}
+		}
+	case []any:
+		for i, value := range v {
+			v[i] = redactJSON(value, fields)
+		}
+	}
+	return v
+}
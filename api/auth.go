@@ -0,0 +1,125 @@
+package api
+
+import (
+	"context"
+	"sgo-api/base"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	principalContextKey = "sgo-api.principal"
+
+	PrincipalTypeJWT    = "jwt"
+	PrincipalTypeAPIKey = "api_key"
+	PrincipalTypeMTLS   = "mtls"
+)
+
+// 认证主体
+type Principal struct {
+	ID          string         `json:"id"`
+	Name        string         `json:"name"`
+	Type        string         `json:"type"` // 认证方式，见 PrincipalTypeJWT 等
+	Roles       []string       `json:"roles"`
+	Permissions []string       `json:"permissions"`
+	Claims      map[string]any `json:"claims"` // JWT 的原始 claims
+}
+
+// 认证器
+type Authenticator interface {
+	// 认证请求。未携带该方式的凭证时返回 nil, nil；凭证无效时返回错误
+	Authenticate(c *gin.Context) (*Principal, error)
+}
+
+type AuthConfig struct {
+	Authenticators []Authenticator // 依次尝试，使用第一个携带了凭证的认证器
+
+	Optional bool                                    // 未携带凭证时放行，但凭证无效时仍然拒绝
+	Check    func(c *gin.Context, p *Principal) bool // 认证成功后的额外检查，返回 false 时响应 403
+	Skip     func(c *gin.Context) bool               // 跳过认证
+}
+
+// 认证中间件，可以全局使用，也可以按路由组使用不同的配置
+//   - 未携带凭证或凭证无效时响应 401，Check 不通过时响应 403
+//   - 认证成功后，可以通过 GetPrincipal 或 PrincipalFromContext 获取认证主体
+func AuthMiddleware(log base.Logger, conf AuthConfig) gin.HandlerFunc {
+	log = log.WithTag("GIN")
+
+	return func(c *gin.Context) {
+		if conf.Skip != nil && conf.Skip(c) {
+			c.Next()
+			return
+		}
+
+		var p *Principal
+		for _, a := range conf.Authenticators {
+			var err error
+			if p, err = a.Authenticate(c); err != nil {
+				getLogger(c, log).Warnf("认证失败：%v", err)
+
+				c.Header("WWW-Authenticate", "Bearer")
+				c.Error(base.NewUnauthorizedErrorf("认证失败"))
+				c.Abort()
+				return
+			}
+			if p != nil {
+				break
+			}
+		}
+
+		if p == nil {
+			if conf.Optional {
+				c.Next()
+				return
+			}
+
+			c.Header("WWW-Authenticate", "Bearer")
+			c.Error(base.NewUnauthorizedErrorf("未认证"))
+			c.Abort()
+			return
+		}
+
+		SetPrincipal(c, p)
+
+		if conf.Check != nil && !conf.Check(c, p) {
+			c.Error(base.NewForbiddenErrorf("无权访问"))
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// 设置认证主体，同时写入 gin 和请求的 context
+func SetPrincipal(c *gin.Context, p *Principal) {
+	c.Set(principalContextKey, p)
+	c.Request = c.Request.WithContext(ContextWithPrincipal(c.Request.Context(), p))
+
+	// 只更新已经创建的请求元数据，不在这里读取请求体。之后创建或结束时会从 gin 的 context 读取
+	if req := lookupRequest(c); req != nil {
+		req.Principal = p
+	}
+}
+
+// 获取认证主体，未认证时返回 nil
+func GetPrincipal(c *gin.Context) *Principal {
+	if v, ok := c.Get(principalContextKey); ok {
+		if p, ok := v.(*Principal); ok {
+			return p
+		}
+	}
+	return nil
+}
+
+func ContextWithPrincipal(ctx context.Context, p *Principal) context.Context {
+	return context.WithValue(ctx, principalContextKey, p)
+}
+
+// 从 context 获取认证主体，未认证时返回 nil
+func PrincipalFromContext(ctx context.Context) *Principal {
+	if p, ok := ctx.Value(principalContextKey).(*Principal); ok {
+		return p
+	}
+	return nil
+}
@@ -0,0 +1,139 @@
+package api
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"sgo-api/base"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/samber/oops"
+)
+
+type APIKey struct {
+	Key         string    `json:"key"` // 明文，或者 sha256:<hex> 格式的摘要，避免在配置中保存明文
+	Name        string    `json:"name"`
+	Roles       []string  `json:"roles"`
+	Permissions []string  `json:"permissions"`
+	ExpiresAt   time.Time `json:"expires_at"` // 过期时间，零值为不过期。轮换时可以给旧 Key 设置过期时间
+}
+
+type APIKeyConfig struct {
+	Header string // 读取 Key 的请求头。默认为 X-Api-Key
+	Query  string // 读取 Key 的查询参数。默认不读取
+
+	Keys []APIKey // 静态 Key
+
+	// 动态加载 Key，用于轮换。加载失败时继续使用上次的 Key
+	Load    func(ctx context.Context) ([]APIKey, error)
+	Refresh time.Duration // 动态加载的间隔。默认为 1 分钟
+
+	Log base.Logger // 记录动态加载失败，为 nil 时不记录
+}
+
+type APIKeyAuthenticator struct {
+	conf APIKeyConfig
+
+	lock     sync.RWMutex
+	keys     map[string]APIKey // sha256 摘要 -> Key
+	loadedAt time.Time
+}
+
+func NewAPIKeyAuthenticator(conf APIKeyConfig) *APIKeyAuthenticator {
+	if conf.Header == "" {
+		conf.Header = "X-Api-Key"
+	}
+	if conf.Refresh <= 0 {
+		conf.Refresh = time.Minute
+	}
+
+	a := &APIKeyAuthenticator{conf: conf}
+	a.keys = a.index(nil)
+	return a
+}
+
+func (a *APIKeyAuthenticator) Authenticate(c *gin.Context) (*Principal, error) {
+	key := c.GetHeader(a.conf.Header)
+	if key == "" && a.conf.Query != "" {
+		key = c.Query(a.conf.Query)
+	}
+	if key == "" {
+		return nil, nil
+	}
+
+	keys := a.load(c.Request.Context())
+
+	k, ok := keys[hashAPIKey(key)]
+	if !ok {
+		return nil, oops.Errorf("API Key 无效")
+	}
+	if !k.ExpiresAt.IsZero() && time.Now().After(k.ExpiresAt) {
+		return nil, oops.Errorf("API Key %v 已过期", k.Name)
+	}
+
+	return &Principal{
+		ID:          k.Name,
+		Name:        k.Name,
+		Type:        PrincipalTypeAPIKey,
+		Roles:       k.Roles,
+		Permissions: k.Permissions,
+	}, nil
+}
+
+func (a *APIKeyAuthenticator) load(ctx context.Context) map[string]APIKey {
+	a.lock.RLock()
+	keys := a.keys
+	fresh := a.conf.Load == nil || time.Since(a.loadedAt) < a.conf.Refresh
+	a.lock.RUnlock()
+
+	if fresh {
+		return keys
+	}
+
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	if time.Since(a.loadedAt) < a.conf.Refresh {
+		return a.keys
+	}
+	// 无论成功与否，都等到下个间隔再加载，避免加载失败时每个请求都去加载
+	a.loadedAt = time.Now()
+
+	loaded, err := a.conf.Load(ctx)
+	if err != nil {
+		if a.conf.Log != nil {
+			a.conf.Log.WithTrace(ctx, nil).WithTag("Auth").Errorf("加载 API Key 失败：%+v", err)
+		}
+		return a.keys
+	}
+
+	a.keys = a.index(loaded)
+	return a.keys
+}
+
+func (a *APIKeyAuthenticator) index(loaded []APIKey) map[string]APIKey {
+	keys := map[string]APIKey{}
+	for _, k := range append(append([]APIKey{}, a.conf.Keys...), loaded...) {
+		if k.Key == "" {
+			continue
+		}
+
+		hash := strings.ToLower(strings.TrimPrefix(k.Key, "sha256:"))
+		if !strings.HasPrefix(k.Key, "sha256:") {
+			hash = hashAPIKey(k.Key)
+		}
+		if k.Name == "" && len(hash) >= 8 {
+			k.Name = hash[:8]
+		}
+		keys[hash] = k
+	}
+	return keys
+}
+
+func hashAPIKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
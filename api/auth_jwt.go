@@ -0,0 +1,451 @@
+package api
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"os"
+	"sgo-api/base"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/samber/oops"
+	"golang.org/x/sync/singleflight"
+)
+
+type JWTConfig struct {
+	Header string // 读取 Token 的请求头。默认为 Authorization
+	Scheme string // 请求头中 Token 的前缀。默认为 Bearer
+	Query  string // 读取 Token 的查询参数，比如 WebSocket 无法设置请求头的场景。默认不读取
+	Cookie string // 读取 Token 的 Cookie。默认不读取
+
+	Algorithms []string // 允许的签名算法，比如 HS256、RS256、ES256。默认根据配置的密钥推断
+
+	Secret        string        // HS 系列算法的密钥
+	PublicKeyFile string        // RS、PS、ES 系列算法的 PEM 公钥文件
+	JWKSFile      string        // JWKS 文件
+	JWKSURL       string        // JWKS 地址
+	JWKSRefresh   time.Duration // JWKS 的缓存时间。默认为 10 分钟
+
+	Issuer   string        // 校验 iss，为空时不校验
+	Audience string        // 校验 aud，为空时不校验
+	Leeway   time.Duration // 校验时间时允许的误差
+
+	Claims JWTClaimsMapping
+}
+
+// claims 到认证主体的映射，支持 realm_access.roles 之类的嵌套路径
+type JWTClaimsMapping struct {
+	ID          string // 默认为 sub
+	Name        string // 默认为 name
+	Roles       string // 默认为 roles，值可以是数组或空格分隔的字符串
+	Permissions string // 默认为 permissions，值可以是数组或空格分隔的字符串，比如 scope
+}
+
+type JWTAuthenticator struct {
+	conf   JWTConfig
+	parser *jwt.Parser
+
+	publicKey crypto.PublicKey
+	jwks      *jwks
+}
+
+func NewJWTAuthenticator(conf JWTConfig) (*JWTAuthenticator, error) {
+	if conf.Header == "" {
+		conf.Header = "Authorization"
+	}
+	if conf.Scheme == "" {
+		conf.Scheme = "Bearer"
+	}
+	if conf.JWKSRefresh <= 0 {
+		conf.JWKSRefresh = 10 * time.Minute
+	}
+	if conf.Claims.ID == "" {
+		conf.Claims.ID = "sub"
+	}
+	if conf.Claims.Name == "" {
+		conf.Claims.Name = "name"
+	}
+	if conf.Claims.Roles == "" {
+		conf.Claims.Roles = "roles"
+	}
+	if conf.Claims.Permissions == "" {
+		conf.Claims.Permissions = "permissions"
+	}
+
+	a := &JWTAuthenticator{conf: conf}
+
+	if conf.PublicKeyFile != "" {
+		data, err := os.ReadFile(conf.PublicKeyFile)
+		if err != nil {
+			return nil, oops.Wrapf(err, "读取公钥 %v 失败", conf.PublicKeyFile)
+		}
+		if a.publicKey, err = parsePublicKeyPEM(data); err != nil {
+			return nil, oops.Wrapf(err, "解析公钥 %v 失败", conf.PublicKeyFile)
+		}
+	}
+
+	if conf.JWKSFile != "" || conf.JWKSURL != "" {
+		a.jwks = &jwks{file: conf.JWKSFile, url: conf.JWKSURL, refresh: conf.JWKSRefresh}
+		if err := a.jwks.init(context.Background()); err != nil {
+			return nil, oops.Wrap(err)
+		}
+	}
+
+	algorithms := conf.Algorithms
+	if len(algorithms) == 0 {
+		if conf.Secret != "" {
+			algorithms = append(algorithms, "HS256", "HS384", "HS512")
+		}
+		if a.publicKey != nil || a.jwks != nil {
+			algorithms = append(algorithms, "RS256", "RS384", "RS512", "PS256", "PS384", "PS512", "ES256", "ES384", "ES512")
+		}
+	}
+	if len(algorithms) == 0 {
+		return nil, oops.Errorf("JWT 未配置密钥")
+	}
+
+	opts := []jwt.ParserOption{
+		jwt.WithValidMethods(algorithms),
+		jwt.WithLeeway(conf.Leeway),
+		jwt.WithExpirationRequired(),
+	}
+	if conf.Issuer != "" {
+		opts = append(opts, jwt.WithIssuer(conf.Issuer))
+	}
+	if conf.Audience != "" {
+		opts = append(opts, jwt.WithAudience(conf.Audience))
+	}
+	a.parser = jwt.NewParser(opts...)
+
+	return a, nil
+}
+
+func (a *JWTAuthenticator) Authenticate(c *gin.Context) (*Principal, error) {
+	token := a.token(c)
+	if token == "" {
+		return nil, nil
+	}
+
+	claims := jwt.MapClaims{}
+	if _, err := a.parser.ParseWithClaims(token, claims, func(t *jwt.Token) (any, error) {
+		return a.key(c.Request.Context(), t)
+	}); err != nil {
+		return nil, oops.Wrapf(err, "JWT 无效")
+	}
+
+	m := map[string]any(claims)
+	return &Principal{
+		ID:          claimString(m, a.conf.Claims.ID),
+		Name:        claimString(m, a.conf.Claims.Name),
+		Type:        PrincipalTypeJWT,
+		Roles:       claimStrings(m, a.conf.Claims.Roles),
+		Permissions: claimStrings(m, a.conf.Claims.Permissions),
+		Claims:      m,
+	}, nil
+}
+
+func (a *JWTAuthenticator) token(c *gin.Context) string {
+	if v := c.GetHeader(a.conf.Header); v != "" {
+		if a.conf.Scheme == "" {
+			return v
+		}
+
+		prefix := a.conf.Scheme + " "
+		if len(v) > len(prefix) && strings.EqualFold(v[:len(prefix)], prefix) {
+			return strings.TrimSpace(v[len(prefix):])
+		}
+	}
+
+	if a.conf.Query != "" {
+		if v := c.Query(a.conf.Query); v != "" {
+			return v
+		}
+	}
+
+	if a.conf.Cookie != "" {
+		if v, err := c.Cookie(a.conf.Cookie); err == nil && v != "" {
+			return v
+		}
+	}
+
+	return ""
+}
+
+func (a *JWTAuthenticator) key(ctx context.Context, t *jwt.Token) (any, error) {
+	switch t.Method.(type) {
+	case *jwt.SigningMethodHMAC:
+		if a.conf.Secret == "" {
+			return nil, oops.Errorf("未配置 HS 密钥")
+		}
+		return []byte(a.conf.Secret), nil
+	}
+
+	if kid, _ := t.Header["kid"].(string); a.jwks != nil && (kid != "" || a.publicKey == nil) {
+		return a.jwks.get(ctx, kid)
+	}
+	if a.publicKey != nil {
+		return a.publicKey, nil
+	}
+	return nil, oops.Errorf("未配置公钥")
+}
+
+func claimValue(claims map[string]any, path string) any {
+	return base.GetMapValue(claims, strings.Split(path, ".")...)
+}
+
+func claimString(claims map[string]any, path string) string {
+	if v := claimValue(claims, path); v != nil {
+		return fmt.Sprint(v)
+	}
+	return ""
+}
+
+func claimStrings(claims map[string]any, path string) []string {
+	switch v := claimValue(claims, path).(type) {
+	case nil:
+		return nil
+	case string:
+		return strings.Fields(v)
+	case []any:
+		ss := []string{}
+		for _, s := range v {
+			ss = append(ss, fmt.Sprint(s))
+		}
+		return ss
+	default:
+		return []string{fmt.Sprint(v)}
+	}
+}
+
+func parsePublicKeyPEM(data []byte) (crypto.PublicKey, error) {
+	if key, err := jwt.ParseRSAPublicKeyFromPEM(data); err == nil {
+		return key, nil
+	}
+	if key, err := jwt.ParseECPublicKeyFromPEM(data); err == nil {
+		return key, nil
+	}
+	return nil, oops.Errorf("不支持的公钥格式")
+}
+
+// JWKS 缓存，kid 不存在时会提前刷新（最多每 10 秒一次）
+//   - 过期但 kid 存在时，在后台刷新，继续使用旧的密钥
+//   - 并发的刷新合并为一次请求，请求时不持有锁，不阻塞其他请求的校验
+type jwks struct {
+	file    string
+	url     string
+	refresh time.Duration
+
+	lock      sync.RWMutex
+	keys      map[string]crypto.PublicKey
+	loadedAt  time.Time
+	triedAt   time.Time
+	lastError error
+
+	group singleflight.Group
+}
+
+func (j *jwks) init(ctx context.Context) error {
+	keys, err := j.load(ctx)
+	if err != nil {
+		return oops.Wrapf(err, "加载 JWKS 失败")
+	}
+
+	j.lock.Lock()
+	defer j.lock.Unlock()
+
+	j.keys = keys
+	j.loadedAt = time.Now()
+	j.triedAt = j.loadedAt
+	return nil
+}
+
+// 重新加载，返回的 channel 在加载结束后关闭
+func (j *jwks) reload(ctx context.Context) <-chan singleflight.Result {
+	// 不使用请求的 ctx 取消加载，其他等待同一次加载的请求不受影响
+	ctx = context.WithoutCancel(ctx)
+	return j.group.DoChan("load", func() (any, error) {
+		j.lock.Lock()
+		if time.Since(j.triedAt) < 10*time.Second {
+			j.lock.Unlock()
+			return nil, nil
+		}
+		j.triedAt = time.Now()
+		j.lock.Unlock()
+
+		keys, err := j.load(ctx)
+
+		j.lock.Lock()
+		defer j.lock.Unlock()
+		if err != nil {
+			j.lastError = err
+		} else {
+			j.keys = keys
+			j.loadedAt = time.Now()
+			j.lastError = nil
+		}
+		return nil, nil
+	})
+}
+
+func (j *jwks) get(ctx context.Context, kid string) (crypto.PublicKey, error) {
+	j.lock.RLock()
+	_, found := j.keys[kid]
+	stale := time.Since(j.loadedAt) >= j.refresh
+	throttled := time.Since(j.triedAt) < 10*time.Second
+	j.lock.RUnlock()
+
+	if !throttled {
+		if kid != "" && !found {
+			select {
+			case <-j.reload(ctx):
+			case <-ctx.Done():
+				return nil, oops.Wrap(ctx.Err())
+			}
+		} else if stale {
+			j.reload(ctx)
+		}
+	}
+
+	j.lock.RLock()
+	defer j.lock.RUnlock()
+
+	if j.keys == nil && j.lastError != nil {
+		return nil, oops.Wrapf(j.lastError, "加载 JWKS 失败")
+	}
+
+	if kid == "" {
+		// 没有 kid 时，只有一个密钥才能确定使用哪个
+		if len(j.keys) == 1 {
+			for _, key := range j.keys {
+				return key, nil
+			}
+		}
+		return nil, oops.Errorf("JWT 缺少 kid")
+	}
+
+	if key, ok := j.keys[kid]; ok {
+		return key, nil
+	}
+	return nil, oops.Errorf("JWKS 中不存在 kid %v", kid)
+}
+
+func (j *jwks) load(ctx context.Context) (map[string]crypto.PublicKey, error) {
+	var data []byte
+	if j.file != "" {
+		var err error
+		if data, err = os.ReadFile(j.file); err != nil {
+			return nil, oops.Wrap(err)
+		}
+	} else {
+		ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+		defer cancel()
+
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, j.url, nil)
+		if err != nil {
+			return nil, oops.Wrap(err)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			return nil, oops.Wrap(err)
+		}
+		defer resp.Body.Close()
+
+		if resp.StatusCode != http.StatusOK {
+			return nil, oops.Errorf("请求 JWKS 响应 %d", resp.StatusCode)
+		}
+		if data, err = io.ReadAll(resp.Body); err != nil {
+			return nil, oops.Wrap(err)
+		}
+	}
+
+	var set struct {
+		Keys []jwk `json:"keys"`
+	}
+	if err := json.Unmarshal(data, &set); err != nil {
+		return nil, oops.Wrapf(err, "解析 JWKS 失败")
+	}
+
+	keys := map[string]crypto.PublicKey{}
+	for _, k := range set.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+
+		key, err := k.publicKey()
+		if err != nil {
+			return nil, oops.Wrapf(err, "解析 JWK %v 失败", k.Kid)
+		}
+		keys[k.Kid] = key
+	}
+	return keys, nil
+}
+
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+func (k jwk) publicKey() (crypto.PublicKey, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := decodeJWKInt(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := decodeJWKInt(k.E)
+		if err != nil {
+			return nil, err
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch k.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, oops.Errorf("不支持的曲线 %v", k.Crv)
+		}
+
+		x, err := decodeJWKInt(k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := decodeJWKInt(k.Y)
+		if err != nil {
+			return nil, err
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+	default:
+		return nil, oops.Errorf("不支持的密钥类型 %v", k.Kty)
+	}
+}
+
+func decodeJWKInt(s string) (*big.Int, error) {
+	b, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(s, "="))
+	if err != nil {
+		return nil, oops.Wrap(err)
+	}
+	return new(big.Int).SetBytes(b), nil
+}
@@ -0,0 +1,60 @@
+package api
+
+import (
+	"sgo-api/base"
+
+	"github.com/gin-gonic/gin"
+)
+
+type MTLSConfig struct {
+	// 从客户端证书中取认证主体的 ID。默认为第一个 URI（比如 SPIFFE ID），没有时为 CommonName。为空时认证失败
+	ID func(cert *ClientCert) string
+
+	// 按 ID 设置角色和权限
+	Roles       map[string][]string
+	Permissions map[string][]string
+}
+
+// 使用 mTLS 客户端证书认证，证书已经在 TLS 握手时由 TLSConfig.ClientCAFile 校验
+type MTLSAuthenticator struct {
+	conf MTLSConfig
+}
+
+func NewMTLSAuthenticator(conf MTLSConfig) *MTLSAuthenticator {
+	if conf.ID == nil {
+		conf.ID = func(cert *ClientCert) string {
+			if len(cert.URIs) > 0 {
+				return cert.URIs[0]
+			}
+			return cert.CommonName
+		}
+	}
+	return &MTLSAuthenticator{conf: conf}
+}
+
+func (a *MTLSAuthenticator) Authenticate(c *gin.Context) (*Principal, error) {
+	cert := GetClientCert(c)
+	if cert == nil {
+		return nil, nil
+	}
+
+	id := a.conf.ID(cert)
+	if id == "" {
+		return nil, base.NewUnauthorizedErrorf("客户端证书 %v 中没有可用作 ID 的 URI 或 CommonName", cert.Subject)
+	}
+
+	return &Principal{
+		ID:          id,
+		Name:        cert.CommonName,
+		Type:        PrincipalTypeMTLS,
+		Roles:       a.conf.Roles[id],
+		Permissions: a.conf.Permissions[id],
+		Claims: map[string]any{
+			"subject":       cert.Subject,
+			"dns_names":     cert.DNSNames,
+			"uris":          cert.URIs,
+			"serial_number": cert.SerialNumber,
+			"fingerprint":   cert.Fingerprint,
+		},
+	}, nil
+}
@@ -0,0 +1,204 @@
+package api
+
+import (
+	"context"
+	"sgo-api/base"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 授权策略，各项条件之间为“且”的关系，未设置的条件不检查
+type Policy struct {
+	AnyRoles       []string // 拥有其中任一角色
+	AllRoles       []string // 拥有所有角色
+	AnyPermissions []string // 拥有其中任一权限
+	AllPermissions []string // 拥有所有权限
+
+	Check func(c *gin.Context, p *Principal) bool // 自定义检查
+}
+
+func (pl Policy) Allow(c *gin.Context, p *Principal) bool {
+	if p == nil {
+		return false
+	}
+
+	if len(pl.AnyRoles) > 0 && !anyOf(pl.AnyRoles, p.HasRole) {
+		return false
+	}
+	if len(pl.AllRoles) > 0 && !allOf(pl.AllRoles, p.HasRole) {
+		return false
+	}
+	if len(pl.AnyPermissions) > 0 && !anyOf(pl.AnyPermissions, p.HasPermission) {
+		return false
+	}
+	if len(pl.AllPermissions) > 0 && !allOf(pl.AllPermissions, p.HasPermission) {
+		return false
+	}
+	if pl.Check != nil && !pl.Check(c, p) {
+		return false
+	}
+
+	return true
+}
+
+func (p *Principal) HasRole(role string) bool {
+	if p == nil {
+		return false
+	}
+
+	for _, r := range p.Roles {
+		if r == role {
+			return true
+		}
+	}
+	return false
+}
+
+// 是否拥有权限，支持通配符：* 表示所有权限，orders:* 表示 orders: 开头的所有权限
+func (p *Principal) HasPermission(permission string) bool {
+	if p == nil {
+		return false
+	}
+
+	for _, pp := range p.Permissions {
+		if pp == permission || pp == "*" {
+			return true
+		}
+		if strings.HasSuffix(pp, "*") && strings.HasPrefix(permission, pp[:len(pp)-1]) {
+			return true
+		}
+	}
+	return false
+}
+
+// 授权中间件，需在 AuthMiddleware 之后使用。未认证时响应 401，策略不通过时响应 403
+func Authorize(policy Policy) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if err := authorize(c, policy); err != nil {
+			c.Error(err)
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// 要求拥有其中任一角色
+func RequireRoles(roles ...string) gin.HandlerFunc {
+	return Authorize(Policy{AnyRoles: roles})
+}
+
+// 要求拥有所有权限
+func RequirePermissions(permissions ...string) gin.HandlerFunc {
+	return Authorize(Policy{AllPermissions: permissions})
+}
+
+func authorize(c *gin.Context, policy Policy) error {
+	p := GetPrincipal(c)
+	if p == nil {
+		return base.NewUnauthorizedErrorf("未认证")
+	}
+	if !policy.Allow(c, p) {
+		return base.NewForbiddenErrorf("无权访问")
+	}
+	return nil
+}
+
+// 按路由模板授权的规则
+type RouteRule struct {
+	Method string // 请求方法，为空时匹配所有方法
+	Path   string // 路由模板（c.FullPath()），比如 /users/:id。以 * 结尾时按前缀匹配，比如 /admin/*
+	Policy Policy
+}
+
+func (r RouteRule) match(method string, path string) bool {
+	return matchRoute(r.Method, r.Path, method, path)
+}
+
+// 匹配路由规则，ruleMethod 为空时匹配所有方法，rulePath 以 * 结尾时按前缀匹配
+func matchRoute(ruleMethod string, rulePath string, method string, path string) bool {
+	if ruleMethod != "" && !strings.EqualFold(ruleMethod, method) {
+		return false
+	}
+
+	if strings.HasSuffix(rulePath, "*") {
+		return strings.HasPrefix(path, rulePath[:len(rulePath)-1])
+	}
+	return rulePath == path
+}
+
+// 声明式地按路由授权，使用第一条匹配的规则，没有匹配的规则时放行
+// 需要在 AuthMiddleware 之后使用：全局认证时设置 Config.Authorize；按路由组认证时在路由组中使用，比如
+//
+//	g := r.Group("/admin", api.AuthMiddleware(log, authConf), api.AuthorizeRoutes(rules...))
+func AuthorizeRoutes(rules ...RouteRule) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		path := c.FullPath()
+		if path == "" {
+			c.Next()
+			return
+		}
+
+		for _, rule := range rules {
+			if !rule.match(c.Request.Method, path) {
+				continue
+			}
+
+			if err := authorize(c, rule.Policy); err != nil {
+				c.Error(err)
+				c.Abort()
+				return
+			}
+			break
+		}
+
+		c.Next()
+	}
+}
+
+// 在处理器中检查权限，没有权限时返回 403 错误
+func CheckPermission(ctx context.Context, permission string) error {
+	p := PrincipalFromContext(ctx)
+	if p == nil {
+		return base.NewUnauthorizedErrorf("未认证")
+	}
+	if !p.HasPermission(permission) {
+		return base.NewForbiddenErrorf("缺少权限 %v", permission)
+	}
+	return nil
+}
+
+// 资源级的授权策略，比如只有订单的所有者才能修改订单
+type ResourcePolicy[T any] func(p *Principal, action string, resource T) bool
+
+// 在处理器中检查对资源的操作权限，没有权限时返回 403 错误
+func Can[T any](ctx context.Context, action string, resource T, policy ResourcePolicy[T]) error {
+	p := PrincipalFromContext(ctx)
+	if p == nil {
+		return base.NewUnauthorizedErrorf("未认证")
+	}
+	if !policy(p, action, resource) {
+		return base.NewForbiddenErrorf("无权 %v 该资源", action)
+	}
+	return nil
+}
+
+func anyOf(values []string, f func(string) bool) bool {
+	for _, v := range values {
+		if f(v) {
+			return true
+		}
+	}
+	return false
+}
+
+func allOf(values []string, f func(string) bool) bool {
+	for _, v := range values {
+		if !f(v) {
+			return false
+		}
+	}
+	return true
+}
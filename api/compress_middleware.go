@@ -0,0 +1,288 @@
+package api
+
+import (
+	"bufio"
+	"compress/gzip"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/andybalholm/brotli"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	EncodingBrotli = "br"
+	EncodingGzip   = "gzip"
+)
+
+type CompressConfig struct {
+	Encodings    []string // 支持的编码，按优先级排列。默认为 br、gzip
+	GzipLevel    int      // 默认为 gzip.DefaultCompression
+	BrotliLevel  int      // 默认为 4，兼顾速度和压缩率
+	MinSize      int      // 响应体小于该大小（字节）时不压缩。默认为 1KB
+	ContentTypes []string // 压缩的类型，支持 text/* 这样的通配。默认为 JSON、文本、JavaScript、XML、SVG
+}
+
+// 响应压缩中间件，需要放在 LogMiddleware 之前，日志中记录的是压缩前的内容
+//   - 按 Accept-Encoding 选择编码，响应带 Vary: Accept-Encoding
+//   - 不压缩：HEAD 请求、已经设置 Content-Encoding 或 Content-Length 的响应（比如 ServeContent 的文件和范围请求）、
+//     没有响应体的状态码、不在 ContentTypes 中的类型（比如 text/event-stream）
+func CompressMiddleware(conf CompressConfig) gin.HandlerFunc {
+	if len(conf.Encodings) == 0 {
+		conf.Encodings = []string{EncodingBrotli, EncodingGzip}
+	}
+	if conf.GzipLevel == 0 {
+		conf.GzipLevel = gzip.DefaultCompression
+	}
+	if conf.BrotliLevel == 0 {
+		conf.BrotliLevel = 4
+	}
+	if conf.MinSize <= 0 {
+		conf.MinSize = 1 << 10
+	}
+	if len(conf.ContentTypes) == 0 {
+		conf.ContentTypes = []string{
+			"application/json",
+			"application/problem+json",
+			"application/x-ndjson",
+			"application/javascript",
+			"application/xml",
+			"image/svg+xml",
+			"text/html",
+			"text/plain",
+			"text/css",
+			"text/csv",
+			"text/xml",
+		}
+	}
+
+	gzipPool := sync.Pool{New: func() any {
+		w, _ := gzip.NewWriterLevel(io.Discard, conf.GzipLevel)
+		return w
+	}}
+	brotliPool := sync.Pool{New: func() any {
+		return brotli.NewWriterLevel(io.Discard, conf.BrotliLevel)
+	}}
+
+	return func(c *gin.Context) {
+		if c.Request.Method == http.MethodHead {
+			c.Next()
+			return
+		}
+
+		encoding := negotiateEncoding(c.GetHeader("Accept-Encoding"), conf.Encodings)
+		if encoding == "" {
+			c.Next()
+			return
+		}
+
+		w := &compressWriter{
+			ResponseWriter: c.Writer,
+			conf:           &conf,
+			encoding:       encoding,
+			newEncoder: func(dst io.Writer) (encoder, func()) {
+				switch encoding {
+				case EncodingBrotli:
+					bw := brotliPool.Get().(*brotli.Writer)
+					bw.Reset(dst)
+					return bw, func() { brotliPool.Put(bw) }
+				default:
+					gw := gzipPool.Get().(*gzip.Writer)
+					gw.Reset(dst)
+					return gw, func() { gzipPool.Put(gw) }
+				}
+			},
+		}
+		c.Writer = w
+		defer func() {
+			w.finish()
+			if c.Writer == w {
+				c.Writer = w.ResponseWriter
+			}
+		}()
+
+		c.Next()
+	}
+}
+
+// 按 Accept-Encoding 和 q 值选择编码，q 值相同时按 supported 的顺序
+func negotiateEncoding(accept string, supported []string) string {
+	if accept == "" {
+		return ""
+	}
+
+	q := map[string]float64{}
+	for _, part := range strings.Split(accept, ",") {
+		name, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		name = strings.ToLower(strings.TrimSpace(name))
+		value := 1.0
+		if k, v, ok := strings.Cut(strings.TrimSpace(params), "="); ok && strings.TrimSpace(k) == "q" {
+			if f, err := strconv.ParseFloat(strings.TrimSpace(v), 64); err == nil {
+				value = f
+			}
+		}
+		q[name] = value
+	}
+
+	best, bestQ := "", 0.0
+	for _, e := range supported {
+		v, ok := q[e]
+		if !ok {
+			v, ok = q["*"]
+		}
+		if ok && v > bestQ {
+			best, bestQ = e, v
+		}
+	}
+	return best
+}
+
+func matchContentType(types []string, contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+
+	for _, t := range types {
+		if t == mediaType || (strings.HasSuffix(t, "/*") && strings.HasPrefix(mediaType, t[:len(t)-1])) {
+			return true
+		}
+	}
+	return false
+}
+
+type encoder interface {
+	io.WriteCloser
+	Flush() error
+}
+
+// 先缓存响应体，达到 MinSize 或刷新时再决定是否压缩
+type compressWriter struct {
+	gin.ResponseWriter
+	conf       *CompressConfig
+	encoding   string
+	newEncoder func(dst io.Writer) (encoder, func())
+
+	buf     []byte
+	decided bool
+	enc     encoder
+	release func()
+}
+
+func (w *compressWriter) Write(b []byte) (int, error) {
+	if !w.decided {
+		w.buf = append(w.buf, b...)
+		if len(w.buf) < w.conf.MinSize {
+			return len(b), nil
+		}
+		if err := w.decide(); err != nil {
+			return 0, err
+		}
+		return len(b), nil
+	}
+
+	if w.enc != nil {
+		return w.enc.Write(b)
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *compressWriter) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
+
+// 响应头已经发出，后续内容长度未知，按类型决定是否压缩
+func (w *compressWriter) WriteHeaderNow() {
+	if !w.decided {
+		w.decide()
+	}
+	w.ResponseWriter.WriteHeaderNow()
+}
+
+func (w *compressWriter) Flush() {
+	if !w.decided {
+		w.decide()
+	}
+	if w.enc != nil {
+		w.enc.Flush()
+	}
+	w.ResponseWriter.Flush()
+}
+
+func (w *compressWriter) Written() bool {
+	return len(w.buf) > 0 || w.ResponseWriter.Written()
+}
+
+func (w *compressWriter) Size() int {
+	if !w.decided && len(w.buf) > 0 {
+		return len(w.buf)
+	}
+	return w.ResponseWriter.Size()
+}
+
+func (w *compressWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	w.decided = true
+	return w.ResponseWriter.Hijack()
+}
+
+func (w *compressWriter) shouldCompress() bool {
+	h := w.Header()
+	status := w.Status()
+	if status < http.StatusOK || status == http.StatusNoContent || status == http.StatusNotModified || status == http.StatusPartialContent {
+		return false
+	}
+	if h.Get("Content-Encoding") != "" || h.Get("Content-Length") != "" || h.Get("Content-Range") != "" {
+		return false
+	}
+	return matchContentType(w.conf.ContentTypes, h.Get("Content-Type"))
+}
+
+func (w *compressWriter) decide() error {
+	w.decided = true
+
+	if w.shouldCompress() {
+		h := w.Header()
+		h.Set("Content-Encoding", w.encoding)
+		h.Add("Vary", "Accept-Encoding")
+		if etag := h.Get("ETag"); etag != "" && !strings.HasPrefix(etag, "W/") {
+			// 压缩后内容不同，强 ETag 改为弱 ETag
+			h.Set("ETag", "W/"+etag)
+		}
+		w.enc, w.release = w.newEncoder(w.ResponseWriter)
+	}
+
+	buf := w.buf
+	w.buf = nil
+	if len(buf) == 0 {
+		return nil
+	}
+	if w.enc != nil {
+		_, err := w.enc.Write(buf)
+		return err
+	}
+	_, err := w.ResponseWriter.Write(buf)
+	return err
+}
+
+// 请求结束时调用：内容不足 MinSize 的原样写出，压缩的写出剩余内容
+func (w *compressWriter) finish() {
+	if !w.decided {
+		w.decided = true
+		if len(w.buf) > 0 {
+			w.ResponseWriter.Write(w.buf)
+			w.buf = nil
+		}
+		return
+	}
+
+	if w.enc != nil {
+		w.enc.Close()
+		w.release()
+		w.enc = nil
+	}
+}
@@ -0,0 +1,121 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/samber/oops"
+)
+
+type CORSConfig struct {
+	AllowOrigins     []string      // 允许的来源，支持 * 和 https://*.example.com 这样的通配。为空时不允许跨域
+	AllowMethods     []string      // 允许的方法。默认为 GET、POST、PUT、PATCH、DELETE、HEAD、OPTIONS
+	AllowHeaders     []string      // 允许的请求头。默认回显预检请求的 Access-Control-Request-Headers
+	ExposeHeaders    []string      // 暴露给浏览器的响应头
+	AllowCredentials bool          // 允许携带 Cookie 等凭证，此时 AllowOrigins 不能包含 *，需要列出来源或使用 https://*.example.com 这样的通配
+	MaxAge           time.Duration // 预检结果的缓存时间。默认为 12 小时
+}
+
+// 跨域中间件，预检请求直接响应 204。配置无效（允许携带凭证且允许所有来源）时恐慌
+func CORSMiddleware(conf CORSConfig) gin.HandlerFunc {
+	if len(conf.AllowMethods) == 0 {
+		conf.AllowMethods = []string{
+			http.MethodGet, http.MethodPost, http.MethodPut, http.MethodPatch,
+			http.MethodDelete, http.MethodHead, http.MethodOptions,
+		}
+	}
+	if conf.MaxAge <= 0 {
+		conf.MaxAge = 12 * time.Hour
+	}
+
+	allowAll := false
+	for _, o := range conf.AllowOrigins {
+		if o == "*" {
+			allowAll = true
+		}
+	}
+
+	// 允许任意来源携带凭证，等于允许任意网站读取已登录用户的响应
+	if allowAll && conf.AllowCredentials {
+		panic(oops.Errorf("CORS 允许携带凭证时，AllowOrigins 不能包含 *"))
+	}
+
+	allowMethods := strings.Join(conf.AllowMethods, ", ")
+	allowHeaders := strings.Join(conf.AllowHeaders, ", ")
+	exposeHeaders := strings.Join(conf.ExposeHeaders, ", ")
+	maxAge := strconv.Itoa(int(conf.MaxAge.Seconds()))
+
+	return func(c *gin.Context) {
+		origin := c.GetHeader("Origin")
+		if origin == "" {
+			c.Next()
+			return
+		}
+
+		c.Writer.Header().Add("Vary", "Origin")
+
+		if !allowAll && !matchOrigin(conf.AllowOrigins, origin) {
+			if isPreflight(c) {
+				c.AbortWithStatus(http.StatusForbidden)
+				return
+			}
+			c.Next()
+			return
+		}
+
+		if allowAll {
+			c.Header("Access-Control-Allow-Origin", "*")
+		} else {
+			c.Header("Access-Control-Allow-Origin", origin)
+		}
+		if conf.AllowCredentials {
+			c.Header("Access-Control-Allow-Credentials", "true")
+		}
+
+		if isPreflight(c) {
+			c.Header("Access-Control-Allow-Methods", allowMethods)
+			if allowHeaders != "" {
+				c.Header("Access-Control-Allow-Headers", allowHeaders)
+			} else if h := c.GetHeader("Access-Control-Request-Headers"); h != "" {
+				c.Header("Access-Control-Allow-Headers", h)
+				c.Writer.Header().Add("Vary", "Access-Control-Request-Headers")
+			}
+			c.Header("Access-Control-Max-Age", maxAge)
+
+			c.AbortWithStatus(http.StatusNoContent)
+			return
+		}
+
+		if exposeHeaders != "" {
+			c.Header("Access-Control-Expose-Headers", exposeHeaders)
+		}
+
+		c.Next()
+	}
+}
+
+func isPreflight(c *gin.Context) bool {
+	return c.Request.Method == http.MethodOptions && c.GetHeader("Access-Control-Request-Method") != ""
+}
+
+func matchOrigin(allowOrigins []string, origin string) bool {
+	for _, o := range allowOrigins {
+		if strings.EqualFold(o, origin) {
+			return true
+		}
+
+		// https://*.example.com 匹配 https://a.example.com，但不匹配 https://example.com
+		if i := strings.Index(o, "*"); i >= 0 {
+			prefix, suffix := o[:i], o[i+1:]
+			if len(origin) > len(prefix)+len(suffix) &&
+				strings.HasPrefix(strings.ToLower(origin), strings.ToLower(prefix)) &&
+				strings.HasSuffix(strings.ToLower(origin), strings.ToLower(suffix)) {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,86 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sgo-api/base"
+	"sync"
+	"time"
+
+	"github.com/samber/oops"
+)
+
+// 崩溃报告，接口发生恐慌时生成
+type CrashReport struct {
+	Time    time.Time `json:"time"`
+	TraceID string    `json:"trace_id"`
+	Error   string    `json:"error"` // 包含 oops 的堆栈
+	Stack   string    `json:"stack"` // 发生恐慌的协程的完整堆栈
+
+	IP        string              `json:"ip"`
+	Method    string              `json:"method"`
+	Path      string              `json:"path"`
+	Route     string              `json:"route"`
+	UserAgent string              `json:"user_agent"`
+	Header    map[string][]string `json:"header"`
+	Body      string              `json:"body"`
+}
+
+// 崩溃上报，比如写入文件、发送到 Sentry 等。在独立的协程中调用，不影响响应
+type CrashReporter interface {
+	Report(ctx context.Context, report CrashReport) error
+}
+
+type CrashReporterFunc func(ctx context.Context, report CrashReport) error
+
+func (f CrashReporterFunc) Report(ctx context.Context, report CrashReport) error {
+	return f(ctx, report)
+}
+
+// 把崩溃报告按天写入 JSON Lines 文件
+type FileCrashReporter struct {
+	dir  string
+	lock sync.Mutex
+}
+
+// dir 为空时，使用 base.LOGPATH/crash，base.LOGPATH 也为空时使用 ./logs/crash
+func NewFileCrashReporter(dir string) (*FileCrashReporter, error) {
+	if dir == "" {
+		dir = base.LOGPATH
+		if dir == "" {
+			dir = "./logs"
+		}
+		dir = filepath.Join(dir, "crash")
+	}
+
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, oops.Wrapf(err, "创建崩溃报告目录失败")
+	}
+
+	return &FileCrashReporter{dir: dir}, nil
+}
+
+func (r *FileCrashReporter) Report(ctx context.Context, report CrashReport) error {
+	data, err := json.Marshal(report)
+	if err != nil {
+		return oops.Wrap(err)
+	}
+	data = append(data, '\n')
+
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	name := filepath.Join(r.dir, report.Time.Format("2006-01-02")+".jsonl")
+	f, err := os.OpenFile(name, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		return oops.Wrapf(err, "打开崩溃报告文件失败")
+	}
+	defer f.Close()
+
+	if _, err := f.Write(data); err != nil {
+		return oops.Wrapf(err, "写入崩溃报告失败")
+	}
+	return nil
+}
@@ -1,75 +1,160 @@
-package api
-
-import (
-	"errors"
-	"fmt"
-	"net/http"
-	"sgo-api/base"
-	"strings"
-
-	"github.com/gin-gonic/gin"
-)
-
-func ErrorMiddleware(log base.Logger, errorCodeMap map[int][]string) gin.HandlerFunc {
-	log = log.WithTag("GIN")
-
-	return func(c *gin.Context) {
-		defer func() {
-			if err := base.Recover(recover()); err != nil {
-				log.Errorf("接口发生恐慌：%+v", err)
-				rep(c, errorCodeMap, err)
-			}
-		}()
-
-		c.Next()
-
-		if len(c.Errors) <= 0 {
-			return
-		}
-
-		err := c.Errors[len(c.Errors)-1]
-
-		sb := strings.Builder{}
-		sb.WriteString(fmt.Sprintf("发生错误：%+v", err.Unwrap()))
-		for i := len(c.Errors) - 2; i >= 0; i-- {
-			sb.WriteString(fmt.Sprintf("\n%+v", c.Errors[i]))
-		}
-		log.Errorf(sb.String())
-
-		rep(c, errorCodeMap, err)
-	}
-}
-
-// 响应错误，返回 true 时说明有错误
-func rep(c *gin.Context, errorCodeMap map[int][]string, err error) bool {
-	if err == nil {
-		return false
-	}
-
-	msg := err.Error()
-
-	// 获取 code
-	code := http.StatusInternalServerError
-
-	var coder interface{ Code() int }
-	if ok := errors.As(err, &coder); ok {
-		code = coder.Code()
-	} else {
-		for c, es := range errorCodeMap {
-			ok := false
-			for _, e := range es {
-				if strings.HasPrefix(msg, e) {
-					code = c
-					ok = true
-					break
-				}
-			}
-			if ok {
-				break
-			}
-		}
-	}
-
-	c.JSON(code, gin.H{"code": code, "msg": msg})
-	return true
-}
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"runtime/debug"
+	"sgo-api/base"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	crashBodyLimit = 64 << 10
+)
+
+type ErrorConfig struct {
+	CodeMap map[int][]string
+
+	// 恐慌时调用，比如 FileCrashReporter。为 nil 时只记录日志
+	CrashReporter CrashReporter
+
+	// 恐慌时向客户端返回恐慌信息。默认只在测试环境返回，其他环境返回“服务器内部错误”
+	ExposePanic bool
+}
+
+func ErrorMiddleware(log base.Logger, errorCodeMap map[int][]string) gin.HandlerFunc {
+	return NewErrorMiddleware(log, ErrorConfig{CodeMap: errorCodeMap})
+}
+
+// 错误中间件，把 c.Errors 中最后一个错误响应给客户端，并处理恐慌：
+//   - 日志记录错误和发生恐慌的协程的完整堆栈
+//   - 非测试环境不向客户端暴露恐慌信息，响应中带上 Trace ID 以便排查
+func NewErrorMiddleware(log base.Logger, conf ErrorConfig) gin.HandlerFunc {
+	log = log.WithTag("GIN")
+	errorCodeMap := conf.CodeMap
+	exposePanic := conf.ExposePanic || base.IsTestENV()
+
+	return func(c *gin.Context) {
+		defer func() {
+			if err := base.Recover(recover()); err != nil {
+				stack := debug.Stack()
+				getLogger(c, log).Errorf("接口发生恐慌：%+v\n%s", err, stack)
+
+				if conf.CrashReporter != nil {
+					reportCrash(c, log, conf.CrashReporter, err, string(stack))
+				}
+
+				if exposePanic {
+					rep(c, errorCodeMap, err)
+				} else {
+					c.JSON(http.StatusInternalServerError, gin.H{
+						"code":     http.StatusInternalServerError,
+						"msg":      "服务器内部错误",
+						"trace_id": GetTraceID(c),
+					})
+				}
+			}
+		}()
+
+		c.Next()
+
+		if len(c.Errors) <= 0 {
+			return
+		}
+
+		err := c.Errors[len(c.Errors)-1]
+
+		sb := strings.Builder{}
+		sb.WriteString(fmt.Sprintf("发生错误：%+v", err.Unwrap()))
+		for i := len(c.Errors) - 2; i >= 0; i-- {
+			sb.WriteString(fmt.Sprintf("\n%+v", c.Errors[i]))
+		}
+		getLogger(c, log).Errorf(sb.String())
+
+		rep(c, errorCodeMap, err)
+	}
+}
+
+// 响应错误，返回 true 时说明有错误
+func rep(c *gin.Context, errorCodeMap map[int][]string, err error) bool {
+	if err == nil {
+		return false
+	}
+
+	msg := err.Error()
+
+	// 获取 code
+	code := http.StatusInternalServerError
+
+	var coder interface{ Code() int }
+	var maxBytesErr *http.MaxBytesError
+	if ok := errors.As(err, &coder); ok {
+		code = coder.Code()
+	} else if ok := errors.As(err, &maxBytesErr); ok {
+		code = http.StatusRequestEntityTooLarge
+	} else if errors.Is(err, context.DeadlineExceeded) {
+		code = http.StatusGatewayTimeout
+	} else {
+		for c, es := range errorCodeMap {
+			ok := false
+			for _, e := range es {
+				if strings.HasPrefix(msg, e) {
+					code = c
+					ok = true
+					break
+				}
+			}
+			if ok {
+				break
+			}
+		}
+	}
+
+	c.JSON(code, gin.H{"code": code, "msg": msg})
+	return true
+}
+
+func reportCrash(c *gin.Context, log base.Logger, reporter CrashReporter, err error, stack string) {
+	req := GetRequest(c)
+
+	header := req.Header.Clone()
+	for _, k := range []string{"Authorization", "Cookie", "X-Api-Key", "Proxy-Authorization"} {
+		if header.Get(k) != "" {
+			header.Set(k, "***")
+		}
+	}
+
+	body, _ := req.Body()
+	if len(body) > crashBodyLimit {
+		body = body[:crashBodyLimit]
+	}
+
+	report := CrashReport{
+		Time:      time.Now(),
+		TraceID:   GetTraceID(c),
+		Error:     fmt.Sprintf("%+v", err),
+		Stack:     stack,
+		IP:        req.IP,
+		Method:    req.Method,
+		Path:      req.Path,
+		Route:     req.Route,
+		UserAgent: req.UserAgent,
+		Header:    header,
+		Body:      string(body),
+	}
+
+	log = getLogger(c, log)
+	ctx := context.WithoutCancel(c.Request.Context())
+	go base.Try(func() {
+		if err := reporter.Report(ctx, report); err != nil {
+			log.Errorf("上报崩溃失败：%+v", err)
+		}
+	}).Catch(func(err error) {
+		log.Errorf("上报崩溃发生恐慌：%+v", err)
+	}).Do()
+}
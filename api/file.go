@@ -0,0 +1,342 @@
+package api
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"io"
+	"mime"
+	"net/http"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"sgo-api/base"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/samber/oops"
+)
+
+var (
+	fileExtRegexp = regexp.MustCompile(`^\.[a-z0-9]{1,16}$`)
+
+	uploadedFileType = reflect.TypeOf(UploadedFile{})
+)
+
+type UploadConfig struct {
+	Storage base.Storage
+
+	MaxFileSize  int64    // 单个文件大小上限（字节）。默认为 10MB
+	MaxTotalSize int64    // 所有文件大小上限（字节）。默认为 100MB
+	MaxFiles     int      // 文件数量上限。默认为 10
+	MaxFieldSize int64    // 普通字段大小上限（字节）。默认为 1MB
+	MaxFields    int      // 普通字段数量上限。默认为 100
+	MaxFormSize  int64    // 所有普通字段大小上限（字节）。默认为 10MB
+	AllowTypes   []string // 允许的文件类型，按内容识别，支持 image/* 这样的通配。为空时不限制
+
+	// 生成存储的 key。默认为 2006/01/02/<NanoID><扩展名>
+	Key func(c *gin.Context, filename string) string
+}
+
+type UploadedFile struct {
+	Field    string `json:"field"`
+	Filename string `json:"filename"` // 客户端上传的文件名，不可信，只用于展示
+
+	base.FileInfo
+}
+
+func (conf *UploadConfig) init() {
+	if conf.MaxFileSize <= 0 {
+		conf.MaxFileSize = 10 << 20
+	}
+	if conf.MaxTotalSize <= 0 {
+		conf.MaxTotalSize = 100 << 20
+	}
+	if conf.MaxFiles <= 0 {
+		conf.MaxFiles = 10
+	}
+	if conf.MaxFieldSize <= 0 {
+		conf.MaxFieldSize = 1 << 20
+	}
+	if conf.MaxFields <= 0 {
+		conf.MaxFields = 100
+	}
+	if conf.MaxFormSize <= 0 {
+		conf.MaxFormSize = 10 << 20
+	}
+	if conf.Key == nil {
+		conf.Key = func(c *gin.Context, filename string) string {
+			ext := strings.ToLower(filepath.Ext(filename))
+			if !fileExtRegexp.MatchString(ext) {
+				ext = ""
+			}
+			return time.Now().Format("2006/01/02/") + base.NewNanoID() + ext
+		}
+	}
+}
+
+// 流式读取 multipart 表单，文件直接写入存储，不会整个读入内存
+//   - 普通字段按 form 标签绑定到 obj，类型为 UploadedFile、*UploadedFile、[]UploadedFile 的字段绑定上传的文件
+//   - 绑定后按 binding 标签校验 obj，obj 为 nil 时不绑定
+//   - 出错时删除已经保存的文件
+func BindUpload(c *gin.Context, conf UploadConfig, obj any) (files []UploadedFile, err error) {
+	conf.init()
+	ctx := c.Request.Context()
+
+	defer func() {
+		if err != nil {
+			deleteUploadedFiles(ctx, conf.Storage, files)
+			files = nil
+		}
+	}()
+
+	mr, err := c.Request.MultipartReader()
+	if err != nil {
+		return nil, base.NewBadRequestError(err)
+	}
+
+	form := map[string][]string{}
+	fields := 0
+	var formSize int64
+	var total int64
+	for {
+		part, err := mr.NextPart()
+		if errors.Is(err, io.EOF) {
+			break
+		} else if err != nil {
+			return files, uploadError(err)
+		}
+
+		if part.FileName() == "" {
+			fields++
+			if fields > conf.MaxFields {
+				return files, base.NewHttpErrorf(http.StatusRequestEntityTooLarge, "字段数量不能超过 %d", conf.MaxFields)
+			}
+
+			limit := min(conf.MaxFieldSize, conf.MaxFormSize-formSize)
+			data, err := io.ReadAll(io.LimitReader(part, limit+1))
+			if err != nil {
+				return files, uploadError(err)
+			}
+			if int64(len(data)) > limit {
+				if limit < conf.MaxFieldSize {
+					return files, base.NewHttpErrorf(http.StatusRequestEntityTooLarge, "字段总大小超过 %d 字节", conf.MaxFormSize)
+				}
+				return files, base.NewHttpErrorf(http.StatusRequestEntityTooLarge, "字段 %v 超过 %d 字节", part.FormName(), conf.MaxFieldSize)
+			}
+			formSize += int64(len(data))
+			form[part.FormName()] = append(form[part.FormName()], string(data))
+			continue
+		}
+
+		if len(files) >= conf.MaxFiles {
+			return files, base.NewBadRequestErrorf("文件数量不能超过 %d", conf.MaxFiles)
+		}
+
+		// 按内容识别类型，不信任客户端声明的类型
+		br := bufio.NewReaderSize(part, 512)
+		head, _ := br.Peek(512)
+		contentType, _, _ := mime.ParseMediaType(http.DetectContentType(head))
+		if !allowType(conf.AllowTypes, contentType) {
+			return files, base.NewHttpErrorf(http.StatusUnsupportedMediaType, "不支持的文件类型 %v", contentType)
+		}
+
+		limit := min(conf.MaxFileSize, conf.MaxTotalSize-total)
+		r := &limitReader{r: br, remaining: limit}
+		info, err := conf.Storage.Put(ctx, conf.Key(c, part.FileName()), r, contentType)
+		if r.exceeded {
+			return files, base.NewHttpErrorf(http.StatusRequestEntityTooLarge, "文件 %v 超过大小限制", part.FileName())
+		} else if err != nil {
+			return files, uploadError(err)
+		}
+		total += info.Size
+
+		files = append(files, UploadedFile{
+			Field:    part.FormName(),
+			Filename: filepath.Base(part.FileName()),
+			FileInfo: info,
+		})
+	}
+
+	if obj == nil {
+		return files, nil
+	}
+
+	if err := binding.MapFormWithTag(obj, form, "form"); err != nil {
+		return files, base.NewBadRequestError(err)
+	}
+	bindUploadedFiles(obj, files)
+	if err := binding.Validator.ValidateStruct(obj); err != nil {
+		return files, base.NewBadRequestError(err)
+	}
+
+	return files, nil
+}
+
+// 上传处理器，绑定表单和文件后调用 handler，handler 出错时删除上传的文件
+func UploadHandler[I any, O any](conf UploadConfig, handler func(ctx context.Context, im I) (O, error)) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var im I
+		files, err := BindUpload(c, conf, &im)
+		if err != nil {
+			c.Error(oops.Wrap(err))
+			return
+		}
+
+		JsonTypedHandlerO(c, func(ctx context.Context) (O, error) {
+			om, err := handler(ctx, im)
+			if err != nil {
+				deleteUploadedFiles(context.WithoutCancel(ctx), conf.Storage, files)
+			}
+			return om, err
+		})
+	}
+}
+
+func uploadError(err error) error {
+	var maxBytesErr *http.MaxBytesError
+	if errors.As(err, &maxBytesErr) {
+		return oops.Wrap(err)
+	}
+	return base.NewBadRequestError(err)
+}
+
+func allowType(allowTypes []string, contentType string) bool {
+	if len(allowTypes) == 0 {
+		return true
+	}
+
+	for _, t := range allowTypes {
+		if t == contentType || (strings.HasSuffix(t, "/*") && strings.HasPrefix(contentType, t[:len(t)-1])) {
+			return true
+		}
+	}
+	return false
+}
+
+func deleteUploadedFiles(ctx context.Context, storage base.Storage, files []UploadedFile) {
+	for _, f := range files {
+		storage.Delete(ctx, f.Key)
+	}
+}
+
+// 按 form 标签把文件设置到 obj 的字段上
+func bindUploadedFiles(obj any, files []UploadedFile) {
+	v := reflect.ValueOf(obj)
+	for v.Kind() == reflect.Pointer {
+		v = v.Elem()
+	}
+	if v.Kind() != reflect.Struct {
+		return
+	}
+
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		name := strings.Split(f.Tag.Get("form"), ",")[0]
+		if name == "" || !f.IsExported() {
+			continue
+		}
+
+		matched := []UploadedFile{}
+		for _, file := range files {
+			if file.Field == name {
+				matched = append(matched, file)
+			}
+		}
+		if len(matched) == 0 {
+			continue
+		}
+
+		fv := v.Field(i)
+		switch {
+		case f.Type == uploadedFileType:
+			fv.Set(reflect.ValueOf(matched[0]))
+		case f.Type == reflect.PointerTo(uploadedFileType):
+			fv.Set(reflect.ValueOf(&matched[0]))
+		case f.Type == reflect.SliceOf(uploadedFileType):
+			fv.Set(reflect.ValueOf(matched))
+		}
+	}
+}
+
+// 超出上限时返回错误，而不是像 io.LimitReader 一样静默截断
+type limitReader struct {
+	r         io.Reader
+	remaining int64
+	exceeded  bool
+}
+
+func (r *limitReader) Read(p []byte) (int, error) {
+	if r.remaining <= 0 {
+		// 多读一个字节，判断是否还有内容
+		var b [1]byte
+		if n, _ := r.r.Read(b[:]); n > 0 {
+			r.exceeded = true
+			return 0, oops.Errorf("超过大小限制")
+		}
+		return 0, io.EOF
+	}
+
+	if int64(len(p)) > r.remaining {
+		p = p[:r.remaining]
+	}
+	n, err := r.r.Read(p)
+	r.remaining -= int64(n)
+	return n, err
+}
+
+type DownloadOptions struct {
+	Filename    string    // Content-Disposition 中的文件名，为空时不设置 Content-Disposition
+	Inline      bool      // 在浏览器中直接打开，而不是下载
+	ContentType string    // 为空时按文件名后缀或内容识别
+	ETag        string    // 不带引号，为空时不设置
+	ModTime     time.Time // 用于 Last-Modified 和 If-Modified-Since
+}
+
+// 响应文件内容，支持范围请求（Range、If-Range）和条件请求（If-None-Match、If-Modified-Since），响应内容不记录到日志
+func ServeContent(c *gin.Context, content io.ReadSeeker, opts DownloadOptions) {
+	SkipResponseCapture(c)
+
+	h := c.Writer.Header()
+	if opts.Filename != "" {
+		disposition := "attachment"
+		if opts.Inline {
+			disposition = "inline"
+		}
+		// 非 ASCII 文件名会按 RFC 2231 编码
+		h.Set("Content-Disposition", mime.FormatMediaType(disposition, map[string]string{"filename": opts.Filename}))
+	}
+	if opts.ContentType != "" {
+		h.Set("Content-Type", opts.ContentType)
+	}
+	if opts.ETag != "" {
+		h.Set("ETag", `"`+opts.ETag+`"`)
+	}
+
+	http.ServeContent(c.Writer, c.Request, opts.Filename, opts.ModTime, content)
+}
+
+// 从存储中下载文件，opts 中未设置的 ContentType、ETag、ModTime 使用存储中的信息
+func ServeStorage(c *gin.Context, storage base.Storage, key string, opts DownloadOptions) {
+	f, info, err := storage.Open(c.Request.Context(), key)
+	if err != nil {
+		c.Error(oops.Wrap(err))
+		return
+	}
+	defer f.Close()
+
+	if opts.ContentType == "" {
+		opts.ContentType = info.ContentType
+	}
+	if opts.ETag == "" {
+		opts.ETag = info.ETag
+	}
+	if opts.ModTime.IsZero() {
+		opts.ModTime = info.ModTime
+	}
+
+	ServeContent(c, f, opts)
+}
@@ -1,100 +1,256 @@
-package api
-
-import (
-	"context"
-	"net/http"
-
-	"github.com/gin-gonic/gin"
-	"github.com/gin-gonic/gin/binding"
-	"github.com/samber/oops"
-)
-
-func JsonHandlerIO[T any](
-	c *gin.Context,
-	im T,
-	handler func(im T) (any, error),
-) {
-	if err := c.ShouldBindBodyWith(&im, binding.JSON); err != nil {
-		c.Error(oops.Wrap(err))
-		return
-	}
-
-	if data, err := handler(im); err != nil {
-		c.Error(oops.Wrap(err))
-	} else {
-		c.JSON(http.StatusOK, gin.H{"code": http.StatusOK, "data": data})
-	}
-}
-
-func JsonHandlerI[T any](
-	c *gin.Context,
-	im T,
-	handler func(im T) error,
-) {
-	if err := c.ShouldBindBodyWith(&im, binding.JSON); err != nil {
-		c.Error(oops.Wrap(err))
-		return
-	}
-
-	if err := handler(im); err != nil {
-		c.Error(oops.Wrap(err))
-	} else {
-		c.JSON(http.StatusOK, gin.H{"code": http.StatusOK})
-	}
-}
-
-func JsonHandlerO(
-	c *gin.Context,
-	handler func() (any, error),
-) {
-	if data, err := handler(); err != nil {
-		c.Error(oops.Wrap(err))
-	} else {
-		c.JSON(http.StatusOK, gin.H{"code": http.StatusOK, "data": data})
-	}
-}
-
-func JsonContextHandlerIO[T any](
-	c *gin.Context,
-	im T,
-	handler func(ctx context.Context, im T) (any, error),
-) {
-	if err := c.ShouldBindBodyWith(&im, binding.JSON); err != nil {
-		c.Error(oops.Wrap(err))
-		return
-	}
-
-	if data, err := handler(c.Request.Context(), im); err != nil {
-		c.Error(oops.Wrap(err))
-	} else {
-		c.JSON(http.StatusOK, gin.H{"code": http.StatusOK, "data": data})
-	}
-}
-
-func JsonContextHandlerI[T any](
-	c *gin.Context,
-	im T,
-	handler func(ctx context.Context, im T) error,
-) {
-	if err := c.ShouldBindBodyWith(&im, binding.JSON); err != nil {
-		c.Error(oops.Wrap(err))
-		return
-	}
-
-	if err := handler(c.Request.Context(), im); err != nil {
-		c.Error(oops.Wrap(err))
-	} else {
-		c.JSON(http.StatusOK, gin.H{"code": http.StatusOK})
-	}
-}
-
-func JsonContextHandlerO(
-	c *gin.Context,
-	handler func(ctx context.Context) (any, error),
-) {
-	if data, err := handler(c.Request.Context()); err != nil {
-		c.Error(oops.Wrap(err))
-	} else {
-		c.JSON(http.StatusOK, gin.H{"code": http.StatusOK, "data": data})
-	}
-}
+package api
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"reflect"
+	"sgo-api/base"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/samber/oops"
+)
+
+func JsonHandlerIO[T any](
+	c *gin.Context,
+	im T,
+	handler func(im T) (any, error),
+) {
+	if err := c.ShouldBindBodyWith(&im, binding.JSON); err != nil {
+		c.Error(oops.Wrap(err))
+		return
+	}
+
+	if data, err := handler(im); err != nil {
+		c.Error(oops.Wrap(err))
+	} else {
+		jsonOK(c, gin.H{"code": http.StatusOK, "data": data})
+	}
+}
+
+func JsonHandlerI[T any](
+	c *gin.Context,
+	im T,
+	handler func(im T) error,
+) {
+	if err := c.ShouldBindBodyWith(&im, binding.JSON); err != nil {
+		c.Error(oops.Wrap(err))
+		return
+	}
+
+	if err := handler(im); err != nil {
+		c.Error(oops.Wrap(err))
+	} else {
+		jsonOK(c, gin.H{"code": http.StatusOK})
+	}
+}
+
+func JsonHandlerO(
+	c *gin.Context,
+	handler func() (any, error),
+) {
+	if data, err := handler(); err != nil {
+		c.Error(oops.Wrap(err))
+	} else {
+		jsonOK(c, gin.H{"code": http.StatusOK, "data": data})
+	}
+}
+
+func JsonContextHandlerIO[T any](
+	c *gin.Context,
+	im T,
+	handler func(ctx context.Context, im T) (any, error),
+) {
+	JsonTypedHandlerIO(c, im, handler)
+}
+
+func JsonContextHandlerI[T any](
+	c *gin.Context,
+	im T,
+	handler func(ctx context.Context, im T) error,
+) {
+	if err := c.ShouldBindBodyWith(&im, binding.JSON); err != nil {
+		c.Error(oops.Wrap(err))
+		return
+	}
+
+	if err := handler(c.Request.Context(), im); err != nil {
+		c.Error(oops.Wrap(err))
+	} else {
+		jsonOK(c, gin.H{"code": http.StatusOK})
+	}
+}
+
+func JsonContextHandlerO(
+	c *gin.Context,
+	handler func(ctx context.Context) (any, error),
+) {
+	JsonTypedHandlerO(c, handler)
+}
+
+// 带输出类型的 JsonContextHandlerIO
+func JsonTypedHandlerIO[I any, O any](
+	c *gin.Context,
+	im I,
+	handler func(ctx context.Context, im I) (O, error),
+) {
+	if err := c.ShouldBindBodyWith(&im, binding.JSON); err != nil {
+		c.Error(oops.Wrap(err))
+		return
+	}
+
+	if data, err := handler(c.Request.Context(), im); err != nil {
+		c.Error(oops.Wrap(err))
+	} else {
+		jsonOK(c, gin.H{"code": http.StatusOK, "data": data})
+	}
+}
+
+// 带输出类型的 JsonContextHandlerO
+func JsonTypedHandlerO[O any](
+	c *gin.Context,
+	handler func(ctx context.Context) (O, error),
+) {
+	if data, err := handler(c.Request.Context()); err != nil {
+		c.Error(oops.Wrap(err))
+	} else {
+		jsonOK(c, gin.H{"code": http.StatusOK, "data": data})
+	}
+}
+
+// 表示没有输入或输出
+type Empty struct{}
+
+// 把带类型的处理器转为 gin 的处理器
+//   - I 为 Empty 时不读取请求体
+//   - O 为 Empty 时响应不带 data
+func TypedHandler[I any, O any](handler func(ctx context.Context, im I) (O, error)) gin.HandlerFunc {
+	_, noInput := any(*new(I)).(Empty)
+	_, noOutput := any(*new(O)).(Empty)
+
+	return func(c *gin.Context) {
+		var im I
+
+		switch {
+		case noInput && noOutput:
+			if _, err := handler(c.Request.Context(), im); err != nil {
+				c.Error(oops.Wrap(err))
+			} else {
+				jsonOK(c, gin.H{"code": http.StatusOK})
+			}
+		case noInput:
+			JsonTypedHandlerO(c, func(ctx context.Context) (O, error) {
+				return handler(ctx, im)
+			})
+		case noOutput:
+			JsonContextHandlerI(c, im, func(ctx context.Context, im I) error {
+				_, err := handler(ctx, im)
+				return err
+			})
+		default:
+			JsonTypedHandlerIO(c, im, handler)
+		}
+	}
+}
+
+// 一行注册带类型的路由，r 为 *Router 时同时生成文档
+//
+//	api.Route(r, http.MethodPost, "/users", createUser)
+//	api.Route(r, http.MethodGet, "/users/:id", api.Transform(getUser, api.MapTo[User, UserDTO](nil)))
+func Route[I any, O any](r gin.IRoutes, method string, path string, handler func(ctx context.Context, im I) (O, error), opts ...RouteOption) {
+	h := TypedHandler(handler)
+
+	router, ok := r.(*Router)
+	if !ok {
+		r.Handle(method, path, h)
+		return
+	}
+
+	var input, output reflect.Type
+	if _, ok := any(*new(I)).(Empty); !ok {
+		input = reflect.TypeFor[I]()
+	}
+	if _, ok := any(*new(O)).(Empty); !ok {
+		output = reflect.TypeFor[O]()
+	}
+	router.handle(method, path, input, output, h, opts)
+}
+
+// 转换处理器的输出，比如把实体映射为 DTO
+func Transform[I any, O any, D any](
+	handler func(ctx context.Context, im I) (O, error),
+	transform func(ctx context.Context, om O) (D, error),
+) func(ctx context.Context, im I) (D, error) {
+	return func(ctx context.Context, im I) (D, error) {
+		om, err := handler(ctx, im)
+		if err != nil {
+			var zeroValue D
+			return zeroValue, oops.Wrap(err)
+		}
+		return transform(ctx, om)
+	}
+}
+
+// 通过 base.Mapper 把 O 映射为 D，用于 Transform。mapper 为 nil 时使用 base.Map
+func MapTo[O any, D any](mapper base.Mapper) func(ctx context.Context, om O) (D, error) {
+	return func(ctx context.Context, om O) (D, error) {
+		var d D
+
+		var err error
+		if mapper != nil {
+			err = mapper.Map(om, &d)
+		} else {
+			err = base.Map(om, &d)
+		}
+		if err != nil {
+			return d, oops.Wrapf(err, "映射输出失败")
+		}
+		return d, nil
+	}
+}
+
+// 响应成功的 JSON。GET、HEAD 请求带弱 ETag，与 If-None-Match 匹配时响应 304
+func jsonOK(c *gin.Context, obj any) {
+	method := c.Request.Method
+	if method != http.MethodGet && method != http.MethodHead {
+		c.JSON(http.StatusOK, obj)
+		return
+	}
+
+	data, err := marshalJSON(obj)
+	if err != nil {
+		c.Error(oops.Wrap(err))
+		return
+	}
+
+	sum := sha256.Sum256(data)
+	etag := `W/"` + hex.EncodeToString(sum[:16]) + `"`
+	c.Header("ETag", etag)
+
+	if etagMatch(c.GetHeader("If-None-Match"), etag) {
+		c.Status(http.StatusNotModified)
+		c.Writer.WriteHeaderNow()
+		return
+	}
+
+	c.Data(http.StatusOK, "application/json; charset=utf-8", data)
+}
+
+// If-None-Match 按弱比较匹配，忽略 W/ 前缀
+func etagMatch(ifNoneMatch string, etag string) bool {
+	if ifNoneMatch == "" {
+		return false
+	}
+
+	etag = strings.TrimPrefix(etag, "W/")
+	for _, v := range strings.Split(ifNoneMatch, ",") {
+		v = strings.TrimSpace(v)
+		if v == "*" || strings.TrimPrefix(v, "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,238 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"sgo-api/base"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/samber/oops"
+)
+
+const (
+	HealthStatusUp   = "up"
+	HealthStatusDown = "down"
+)
+
+type HealthCheckFunc func(ctx context.Context) error
+
+type HealthCheck struct {
+	Name     string
+	Check    HealthCheckFunc
+	Timeout  time.Duration // 检查超时时间。默认使用 HealthConfig.Timeout
+	Liveness bool          // 同时用于存活检查。默认只用于就绪检查，存活检查失败会导致服务被重启，需谨慎
+}
+
+type HealthConfig struct {
+	LivenessPath  string        // 存活检查路径。默认为 /healthz
+	ReadinessPath string        // 就绪检查路径。默认为 /readyz
+	Timeout       time.Duration // 单个检查的超时时间。默认为 3s
+	CacheTTL      time.Duration // 检查结果的缓存时间，避免探针频繁访问依赖。默认为 2s
+	ShutdownDelay time.Duration // 开始关闭后，就绪检查先返回失败，等待多久再关闭服务，以便负载均衡摘除流量。默认为 5s
+
+	// 在 Routes 注册的接口中返回检查失败的错误信息。错误可能包含主机名、连接串等，默认只记录日志，响应中只有名称和状态
+	// 管理端口通过 AdminRoutes 注册，总是返回错误信息
+	ExposeErrors bool
+	Log          base.Logger // 记录检查失败的错误。默认为 base.DefaultLogger()
+}
+
+type HealthReport struct {
+	Status string                       `json:"status"`
+	Checks map[string]HealthCheckResult `json:"checks"`
+}
+
+type HealthCheckResult struct {
+	Status    string    `json:"status"`
+	Error     string    `json:"error,omitempty"`
+	Latency   int64     `json:"latency"` // 毫秒
+	CheckedAt time.Time `json:"checked_at"`
+}
+
+// 健康检查，各组件注册检查项，通过 /healthz（存活）和 /readyz（就绪）暴露
+type Health struct {
+	conf HealthConfig
+
+	lock   sync.RWMutex
+	checks []*healthCheck
+
+	shuttingDown atomic.Bool
+}
+
+type healthCheck struct {
+	HealthCheck
+
+	lock   sync.Mutex
+	result *HealthCheckResult
+}
+
+func NewHealth(conf HealthConfig) *Health {
+	if conf.LivenessPath == "" {
+		conf.LivenessPath = "/healthz"
+	}
+	if conf.ReadinessPath == "" {
+		conf.ReadinessPath = "/readyz"
+	}
+	if conf.Timeout <= 0 {
+		conf.Timeout = 3 * time.Second
+	}
+	if conf.CacheTTL <= 0 {
+		conf.CacheTTL = 2 * time.Second
+	}
+	if conf.ShutdownDelay <= 0 {
+		conf.ShutdownDelay = 5 * time.Second
+	}
+	if conf.Log == nil {
+		conf.Log = base.DefaultLogger()
+	}
+	conf.Log = conf.Log.WithTag("HEALTH")
+
+	return &Health{conf: conf}
+}
+
+func (h *Health) Register(checks ...HealthCheck) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	for _, c := range checks {
+		if c.Timeout <= 0 {
+			c.Timeout = h.conf.Timeout
+		}
+		h.checks = append(h.checks, &healthCheck{HealthCheck: c})
+	}
+}
+
+// 注册检查函数，只用于就绪检查
+func (h *Health) RegisterFunc(name string, check HealthCheckFunc) {
+	h.Register(HealthCheck{Name: name, Check: check})
+}
+
+// 注册存活和就绪检查的路由，未设置 ExposeErrors 时响应中不带错误信息
+func (h *Health) Routes(r gin.IRoutes) {
+	h.routes(r, h.conf.ExposeErrors)
+}
+
+// 在管理端口注册存活和就绪检查的路由，响应中带错误信息
+func (h *Health) AdminRoutes(r gin.IRoutes) {
+	h.routes(r, true)
+}
+
+func (h *Health) routes(r gin.IRoutes, exposeErrors bool) {
+	r.GET(h.conf.LivenessPath, func(c *gin.Context) {
+		h.respond(c, h.Liveness(c.Request.Context()), exposeErrors)
+	})
+	r.GET(h.conf.ReadinessPath, func(c *gin.Context) {
+		h.respond(c, h.Readiness(c.Request.Context()), exposeErrors)
+	})
+}
+
+// 标记服务开始关闭，之后就绪检查返回失败
+func (h *Health) Shutdown() {
+	h.shuttingDown.Store(true)
+}
+
+func (h *Health) ShutdownDelay() time.Duration {
+	return h.conf.ShutdownDelay
+}
+
+func (h *Health) Liveness(ctx context.Context) HealthReport {
+	return h.run(ctx, true)
+}
+
+func (h *Health) Readiness(ctx context.Context) HealthReport {
+	report := h.run(ctx, false)
+
+	if h.shuttingDown.Load() {
+		report.Status = HealthStatusDown
+		report.Checks["shutdown"] = HealthCheckResult{
+			Status:    HealthStatusDown,
+			Error:     "服务正在关闭",
+			CheckedAt: time.Now(),
+		}
+	}
+
+	return report
+}
+
+func (h *Health) run(ctx context.Context, liveness bool) HealthReport {
+	h.lock.RLock()
+	checks := []*healthCheck{}
+	for _, c := range h.checks {
+		if !liveness || c.Liveness {
+			checks = append(checks, c)
+		}
+	}
+	h.lock.RUnlock()
+
+	results, _, _ := base.GoPool(ctx, base.PoolConfig{Concurrent: len(checks)}, func(ctx context.Context, c *healthCheck) (HealthCheckResult, error) {
+		return c.run(ctx, h.conf.CacheTTL, h.conf.Log), nil
+	}, checks...)
+
+	report := HealthReport{
+		Status: HealthStatusUp,
+		Checks: map[string]HealthCheckResult{},
+	}
+	for i, c := range checks {
+		r := results[i]
+		if r.Status == "" {
+			r = HealthCheckResult{Status: HealthStatusDown, Error: "未执行", CheckedAt: time.Now()}
+		}
+		if r.Status != HealthStatusUp {
+			report.Status = HealthStatusDown
+		}
+		report.Checks[c.Name] = r
+	}
+	return report
+}
+
+func (c *healthCheck) run(ctx context.Context, ttl time.Duration, log base.Logger) HealthCheckResult {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if c.result != nil && time.Since(c.result.CheckedAt) < ttl {
+		return *c.result
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, c.Timeout)
+	defer cancel()
+
+	start := time.Now()
+	var err error
+	base.Try(func() {
+		err = c.Check(ctx)
+	}).Catch(func(e error) {
+		err = oops.Wrapf(e, "恐慌")
+	}).Do()
+
+	result := HealthCheckResult{
+		Status:    HealthStatusUp,
+		Latency:   time.Since(start).Milliseconds(),
+		CheckedAt: time.Now(),
+	}
+	if err != nil {
+		result.Status = HealthStatusDown
+		result.Error = err.Error()
+		log.Warnf("健康检查 %v 失败：%+v", c.Name, err)
+	}
+
+	c.result = &result
+	return result
+}
+
+func (h *Health) respond(c *gin.Context, report HealthReport, exposeErrors bool) {
+	code := http.StatusOK
+	if report.Status != HealthStatusUp {
+		code = http.StatusServiceUnavailable
+	}
+
+	if !exposeErrors {
+		for name, r := range report.Checks {
+			r.Error = ""
+			report.Checks[name] = r
+		}
+	}
+
+	c.JSON(code, gin.H{"code": code, "data": report})
+}
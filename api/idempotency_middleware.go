@@ -0,0 +1,202 @@
+package api
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"sgo-api/base"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/samber/oops"
+)
+
+type IdempotencyConfig struct {
+	Store base.IdempotencyStore // 默认为 base.MemoryIdempotencyStore，多实例部署时使用 db.IdempotencyStore
+
+	Header       string        // 默认为 Idempotency-Key
+	Methods      []string      // 默认为 POST
+	TTL          time.Duration // 记录的保留时间。默认为 24h
+	LockTimeout  time.Duration // 处理中的记录的占用时间，超过后同一个 key 的请求可以重新执行，避免实例崩溃后 key 一直处于处理中。需要大于接口的最长处理时间。默认为 1min
+	Required     bool          // 缺少 Idempotency-Key 时响应 400。默认不处理，直接执行
+	MaxKeyLength int           // 默认为 128
+
+	// 区分不同调用方的 key，避免不同用户的 key 冲突。默认使用认证主体的 ID
+	// 返回空字符串时响应 401，不允许不同调用方共用 key。不需要认证的接口可以按客户端标识（比如 API Key、设备 ID）区分
+	Scope func(c *gin.Context) string
+}
+
+// 响应回放时不带的响应头，由本次请求重新生成
+var idempotencySkipHeaders = []string{
+	"Content-Encoding", "Content-Length", "Vary", "Date", "Set-Cookie", "X-Request-Id",
+}
+
+// 幂等中间件，同一个 Idempotency-Key 只执行一次，重试时返回第一次的响应
+//   - 需要放在 LogMiddleware、AuthMiddleware 之后，才能取到请求体、响应和认证主体。按路由组认证时，在路由组中 AuthMiddleware 之后使用
+//   - 使用默认的 Scope 时，未认证的请求带 Idempotency-Key 会响应 401
+//   - 重放的响应带 Idempotent-Replayed: true
+//   - 同一个 key 的请求还在处理中（未超过 LockTimeout）时响应 409，用于不同的请求（方法、路径或请求体不同）时响应 422
+//   - 处理出错（c.Errors 不为空或 5xx）时不保存响应，客户端可以用同一个 key 重试
+//   - 文件上传等流式请求体不读取，无法判断 key 是否用于不同的请求，带 Idempotency-Key 时响应 400
+func IdempotencyMiddleware(log base.Logger, conf IdempotencyConfig) gin.HandlerFunc {
+	log = log.WithTag("IDEMPOTENCY")
+
+	if conf.Store == nil {
+		conf.Store = base.NewMemoryIdempotencyStore()
+	}
+	if conf.Header == "" {
+		conf.Header = "Idempotency-Key"
+	}
+	if len(conf.Methods) == 0 {
+		conf.Methods = []string{http.MethodPost}
+	}
+	if conf.TTL <= 0 {
+		conf.TTL = 24 * time.Hour
+	}
+	if conf.LockTimeout <= 0 {
+		conf.LockTimeout = time.Minute
+	}
+	if conf.MaxKeyLength <= 0 {
+		conf.MaxKeyLength = 128
+	}
+	if conf.Scope == nil {
+		conf.Scope = func(c *gin.Context) string {
+			if p := GetPrincipal(c); p != nil {
+				return p.Type + ":" + p.ID
+			}
+			return ""
+		}
+	}
+
+	methods := map[string]bool{}
+	for _, m := range conf.Methods {
+		methods[strings.ToUpper(m)] = true
+	}
+
+	return func(c *gin.Context) {
+		if !methods[c.Request.Method] {
+			c.Next()
+			return
+		}
+
+		key := c.GetHeader(conf.Header)
+		if key == "" {
+			if conf.Required {
+				c.Error(base.NewBadRequestErrorf("缺少 %v", conf.Header))
+				c.Abort()
+				return
+			}
+			c.Next()
+			return
+		}
+		if len(key) > conf.MaxKeyLength {
+			c.Error(base.NewBadRequestErrorf("%v 不能超过 %d 个字符", conf.Header, conf.MaxKeyLength))
+			c.Abort()
+			return
+		}
+
+		scope := conf.Scope(c)
+		if scope == "" {
+			c.Error(base.NewUnauthorizedErrorf("使用 %v 需要先认证", conf.Header))
+			c.Abort()
+			return
+		}
+
+		req := GetRequest(c)
+		if req.BodySkipped {
+			c.Error(base.NewBadRequestErrorf("流式请求体（%v）不支持 %v", req.ContentType, conf.Header))
+			c.Abort()
+			return
+		}
+		body, err := req.Body()
+		if err != nil {
+			c.Error(oops.Wrap(err))
+			c.Abort()
+			return
+		}
+		sum := sha256.Sum256([]byte(c.Request.Method + " " + req.Path + "\n" + string(body)))
+
+		ctx := c.Request.Context()
+		now := time.Now()
+		record := base.IdempotencyRecord{
+			Key:         scope + "|" + key,
+			RequestHash: hex.EncodeToString(sum[:]),
+			CreatedAt:   now,
+			LockedUntil: now.Add(conf.LockTimeout),
+			ExpiresAt:   now.Add(conf.TTL),
+		}
+
+		existing, err := conf.Store.Claim(ctx, record)
+		if err != nil {
+			c.Error(oops.Wrap(err))
+			c.Abort()
+			return
+		}
+		if existing != nil {
+			replayIdempotency(c, existing, record.RequestHash)
+			return
+		}
+
+		w, ok := c.Writer.(*Writer)
+		if !ok {
+			w = NewWriter(c.Writer)
+			c.Writer = w
+		}
+
+		completed := false
+		defer func() {
+			if completed {
+				return
+			}
+			// 出错或恐慌，释放 key，允许重试
+			if err := conf.Store.Release(context.WithoutCancel(ctx), record.Key); err != nil {
+				log.Errorf("释放幂等记录 %v 失败：%+v", record.Key, err)
+			}
+		}()
+
+		c.Next()
+
+		status := w.Status()
+		if len(c.Errors) > 0 || status >= http.StatusInternalServerError || w.Skipped() {
+			return
+		}
+
+		header := w.Header().Clone()
+		for _, h := range idempotencySkipHeaders {
+			header.Del(h)
+		}
+		record.Status = status
+		record.Header = header
+		record.Body = []byte(w.String())
+		if err := conf.Store.Complete(context.WithoutCancel(ctx), record); err != nil {
+			log.Errorf("保存幂等记录 %v 失败：%+v", record.Key, err)
+			return
+		}
+		completed = true
+	}
+}
+
+func replayIdempotency(c *gin.Context, record *base.IdempotencyRecord, requestHash string) {
+	if record.RequestHash != requestHash {
+		c.Error(base.NewHttpErrorf(http.StatusUnprocessableEntity, "Idempotency-Key 已用于其他请求"))
+		c.Abort()
+		return
+	}
+	if !record.Completed() {
+		c.Error(base.NewHttpErrorf(http.StatusConflict, "请求正在处理中，请稍后重试"))
+		c.Abort()
+		return
+	}
+
+	h := c.Writer.Header()
+	for k, v := range record.Header {
+		h[k] = v
+	}
+	h.Set("Idempotent-Replayed", "true")
+
+	c.Status(record.Status)
+	c.Writer.Write(record.Body)
+	c.Abort()
+}
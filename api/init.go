@@ -1,46 +1,246 @@
-package api
-
-import (
-	"sgo-api/base"
-	"strconv"
-
-	"github.com/gin-gonic/gin"
-)
-
-type Config struct {
-	Log base.Logger
-
-	Port int
-
-	TraceContextKey string
-	GetTraceID      func(c *gin.Context) string
-	ErrorCodeMap    map[int][]string
-}
-
-func Init(conf Config, extend func(*gin.Engine)) {
-	if conf.Port <= 0 {
-		conf.Port = 80
-	}
-
-	log := conf.Log.WithTag("GIN")
-
-	gin.SetMode(gin.ReleaseMode)
-
-	r := gin.New()
-
-	// 中间件
-	r.Use(gin.Recovery()) // ErrorMiddleware 已经处理了恐慌问题，这里作为最后一道保险
-	r.Use(LogMiddleware(log, conf.TraceContextKey, conf.GetTraceID))
-	r.Use(ErrorMiddleware(log, conf.ErrorCodeMap))
-
-	if extend != nil {
-		extend(r)
-	}
-
-	for {
-		log.Infof("运行端口：%d", conf.Port)
-		if err := r.Run(":" + strconv.Itoa(conf.Port)); err != nil {
-			log.Errorf("运行出错：%+v", err)
-		}
-	}
-}
+package api
+
+import (
+	"context"
+	"net/http"
+	"os/signal"
+	"sgo-api/base"
+	"strconv"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/samber/oops"
+)
+
+type Config struct {
+	Log base.Logger
+
+	Port      int              // 未设置 Listeners 时监听的端口。默认为 80
+	Listeners []ListenerConfig // 监听地址，可以同时监听多个，比如 HTTPS 端口和 unix socket
+	H2C       bool             // 非 TLS 的监听地址支持 HTTP/2 明文（h2c），用于网关和服务之间的 HTTP/2 转发
+	Admin     *AdminConfig     // 管理端口，为 nil 时不监听
+
+	TraceContextKey string
+	GetTraceID      func(c *gin.Context) string
+	ErrorCodeMap    map[int][]string
+	CrashReporter   CrashReporter // 接口发生恐慌时上报，为 nil 时只记录日志
+
+	RequestID       *RequestIDConfig       // 请求 ID，为 nil 时不处理。未设置 GetTraceID 时，使用请求 ID 作为 Trace ID
+	CORS            *CORSConfig            // 跨域，为 nil 时不处理
+	SecurityHeaders *SecurityHeadersConfig // 安全响应头，为 nil 时不设置
+	MaxBodySize     int64                  // 请求体大小上限（字节），超出时响应 413。默认为 0，即不限制
+	Compress        *CompressConfig        // 响应压缩，为 nil 时不压缩
+	Timeout         *TimeoutConfig         // 请求超时，为 nil 时不设置
+
+	RateLimit *RateLimitConfig // 全局限流，为 nil 时不限流
+	Auth      *AuthConfig      // 全局认证，为 nil 时不认证。也可以在 extend 中按路由组使用 AuthMiddleware
+	Authorize []RouteRule      // 按路由授权，在全局认证之后执行，必须同时设置 Auth。按路由组认证时在路由组中使用 AuthorizeRoutes
+	Audit     *AuditConfig     // 审计，为 nil 时不审计
+
+	// 幂等，为 nil 时不处理 Idempotency-Key。按认证主体区分 key，需要同时设置 Auth；按路由组认证时，在路由组中 AuthMiddleware 之后使用 IdempotencyMiddleware
+	Idempotency *IdempotencyConfig
+
+	Health          *Health            // 健康检查，为 nil 时不注册 /healthz 和 /readyz
+	Diagnostics     *DiagnosticsConfig // 在主端口注册诊断接口（pprof 等），必须设置 Token。只在管理端口注册时使用 AdminConfig.Diagnostics
+	OpenAPI         *OpenAPI           // 接口文档，为 nil 时不注册。在 extend 中通过 OpenAPI.Router 注册带类型的路由
+	Versions        *Versions          // 多版本路由，在 extend 中通过 Versions.Group 注册各版本的路由。按请求头或 Accept 协商版本时必须设置
+	ShutdownTimeout time.Duration      // 收到退出信号后，等待处理中的请求结束的时间。默认为 30s
+}
+
+func Init(conf Config, extend func(*gin.Engine)) {
+	if conf.Port <= 0 {
+		conf.Port = 80
+	}
+	if conf.ShutdownTimeout <= 0 {
+		conf.ShutdownTimeout = 30 * time.Second
+	}
+
+	log := conf.Log.WithTag("GIN")
+
+	if len(conf.Authorize) > 0 && conf.Auth == nil {
+		panic(oops.Errorf("设置 Authorize 时必须设置 Auth，按路由组认证时在路由组中使用 AuthorizeRoutes"))
+	}
+
+	gin.SetMode(gin.ReleaseMode)
+
+	r := gin.New()
+
+	r.Use(gin.Recovery()) // ErrorMiddleware 已经处理了恐慌问题，这里作为最后一道保险
+
+	// 健康检查在其他中间件之前注册，探针请求不记录日志、不限流、不认证
+	if conf.Health != nil {
+		conf.Health.Routes(r)
+	}
+
+	// 诊断接口同样不经过全局中间件，使用自己的令牌认证
+	if conf.Diagnostics != nil {
+		if conf.Diagnostics.Token == "" {
+			panic(oops.Errorf("在主端口注册诊断接口时必须设置 Token"))
+		}
+		Diagnostics(r.Group("", NewErrorMiddleware(log, ErrorConfig{CodeMap: conf.ErrorCodeMap})), log, *conf.Diagnostics)
+	}
+
+	// 中间件
+	r.Use(middlewares(conf, log)...)
+
+	if conf.OpenAPI != nil {
+		conf.OpenAPI.Routes(r)
+	}
+
+	if extend != nil {
+		extend(r)
+	}
+
+	var handler http.Handler = r
+	if conf.Versions != nil {
+		handler = conf.Versions.Handler(r)
+	}
+
+	listeners := conf.Listeners
+	if len(listeners) == 0 {
+		listeners = []ListenerConfig{{Addr: ":" + strconv.Itoa(conf.Port)}}
+	}
+
+	servers := []*server{}
+	for _, l := range listeners {
+		srv, err := newServer(l, handler, conf.H2C, log)
+		if err != nil {
+			panic(oops.Wrapf(err, "创建服务 %v 失败", l.Addr))
+		}
+		servers = append(servers, srv)
+	}
+
+	if conf.Admin != nil {
+		admin := gin.New()
+		admin.Use(gin.Recovery(), NewErrorMiddleware(log, ErrorConfig{CodeMap: conf.ErrorCodeMap}))
+		if conf.Health != nil {
+			conf.Health.AdminRoutes(admin)
+		}
+		if conf.Admin.Diagnostics != nil {
+			if conf.Admin.Diagnostics.Token == "" && !isLocalAddr(conf.Admin.Listener.Addr) {
+				panic(oops.Errorf("管理端口 %v 不是本机地址或 unix socket，诊断接口必须设置 Token", conf.Admin.Listener.Addr))
+			}
+			Diagnostics(admin, log, *conf.Admin.Diagnostics)
+		}
+		if conf.Admin.Extend != nil {
+			conf.Admin.Extend(admin)
+		}
+
+		srv, err := newServer(conf.Admin.Listener, admin, false, log.WithTag("ADMIN"))
+		if err != nil {
+			panic(oops.Wrapf(err, "创建管理服务 %v 失败", conf.Admin.Listener.Addr))
+		}
+		servers = append(servers, srv)
+	}
+
+	run(conf, log, servers)
+}
+
+// 运行服务，收到 SIGINT、SIGTERM 后优雅退出：
+//   - 就绪检查先返回失败，等待负载均衡摘除流量
+//   - 不再接受新连接，等待处理中的请求结束
+func run(conf Config, log base.Logger, servers []*server) {
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+
+		<-ctx.Done()
+		stop()
+
+		if conf.Health != nil {
+			conf.Health.Shutdown()
+			log.Infof("开始关闭，等待 %v 后停止接收请求", conf.Health.ShutdownDelay())
+			time.Sleep(conf.Health.ShutdownDelay())
+		}
+
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), conf.ShutdownTimeout)
+		defer cancel()
+
+		wg := sync.WaitGroup{}
+		for _, srv := range servers {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				srv.shutdown(shutdownCtx, log)
+			}()
+		}
+		wg.Wait()
+	}()
+
+	wg := sync.WaitGroup{}
+	for _, srv := range servers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			srv.run(ctx, log)
+		}()
+	}
+	wg.Wait()
+
+	<-done
+	log.Infof("已关闭")
+}
+
+// 内置中间件，顺序很重要：
+//   - 请求 ID 最先，日志才能使用它作为 Trace ID
+//   - 请求体限制在日志之前，日志读取请求体时也会受到限制
+//   - 压缩在日志之前，日志记录的是压缩前的响应
+//   - 幂等在认证、审计之后，按认证主体区分 key，重放的请求也会审计
+//   - 跨域、安全响应头在错误处理之后，错误响应也会带上这些响应头；跨域的预检请求在限流、认证之前结束
+func middlewares(conf Config, log base.Logger) []gin.HandlerFunc {
+	ms := []gin.HandlerFunc{}
+
+	getTraceID := conf.GetTraceID
+	if conf.RequestID != nil {
+		ms = append(ms, RequestIDMiddleware(*conf.RequestID))
+		if getTraceID == nil {
+			getTraceID = GetRequestID
+		}
+	}
+	if conf.MaxBodySize > 0 {
+		ms = append(ms, BodyLimitMiddleware(conf.MaxBodySize))
+	}
+	if conf.Compress != nil {
+		ms = append(ms, CompressMiddleware(*conf.Compress))
+	}
+
+	ms = append(ms,
+		LogMiddleware(log, conf.TraceContextKey, getTraceID),
+		NewErrorMiddleware(log, ErrorConfig{CodeMap: conf.ErrorCodeMap, CrashReporter: conf.CrashReporter}),
+	)
+
+	if conf.CORS != nil {
+		ms = append(ms, CORSMiddleware(*conf.CORS))
+	}
+	if conf.SecurityHeaders != nil {
+		ms = append(ms, SecurityHeadersMiddleware(*conf.SecurityHeaders))
+	}
+	if conf.Versions != nil {
+		ms = append(ms, conf.Versions.Middleware())
+	}
+	if conf.Timeout != nil {
+		ms = append(ms, TimeoutMiddleware(*conf.Timeout))
+	}
+	if conf.RateLimit != nil {
+		ms = append(ms, RateLimitMiddleware(log, *conf.RateLimit))
+	}
+	if conf.Auth != nil {
+		ms = append(ms, AuthMiddleware(log, *conf.Auth))
+	}
+	if len(conf.Authorize) > 0 {
+		ms = append(ms, AuthorizeRoutes(conf.Authorize...))
+	}
+	if conf.Audit != nil {
+		ms = append(ms, AuditMiddleware(log, *conf.Audit))
+	}
+	if conf.Idempotency != nil {
+		ms = append(ms, IdempotencyMiddleware(log, *conf.Idempotency))
+	}
+
+	return ms
+}
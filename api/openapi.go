@@ -0,0 +1,324 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"reflect"
+	"regexp"
+	"sgo-api/base"
+	"strings"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+)
+
+var (
+	pathParamRegexp = regexp.MustCompile(`[:*](\w+)`)
+)
+
+type OpenAPIConfig struct {
+	Title       string // 默认为 API
+	Version     string // 默认为 1.0.0
+	Description string
+	Servers     []string // 服务地址，比如 https://api.example.com
+
+	Path string // 文档路径。默认为 /openapi.json
+
+	// 字段没有 json 标签时的命名方式。默认为 base.CaseToSnake，与 base.InitJsoniter 一致
+	FieldName func(name string) string
+}
+
+// OpenAPI 3.1 文档，通过 Router 注册带类型的路由时自动收集
+type OpenAPI struct {
+	conf OpenAPIConfig
+
+	lock       sync.Mutex
+	operations []*Operation
+}
+
+// 文档中的一个接口
+type Operation struct {
+	Method      string
+	Path        string
+	OperationID string
+	Summary     string
+	Description string
+	Tags        []string
+	Deprecated  bool
+
+	Query  []string     // 查询参数，按字符串描述
+	Input  reflect.Type // 请求体类型，为 nil 时没有请求体
+	Output reflect.Type // 响应 data 的类型，为 nil 时响应没有 data
+}
+
+type RouteOption func(op *Operation)
+
+func WithSummary(summary string) RouteOption {
+	return func(op *Operation) {
+		op.Summary = summary
+	}
+}
+
+func WithDescription(description string) RouteOption {
+	return func(op *Operation) {
+		op.Description = description
+	}
+}
+
+func WithTags(tags ...string) RouteOption {
+	return func(op *Operation) {
+		op.Tags = append(op.Tags, tags...)
+	}
+}
+
+func WithDeprecated() RouteOption {
+	return func(op *Operation) {
+		op.Deprecated = true
+	}
+}
+
+func WithOperationID(id string) RouteOption {
+	return func(op *Operation) {
+		op.OperationID = id
+	}
+}
+
+func NewOpenAPI(conf OpenAPIConfig) *OpenAPI {
+	if conf.Title == "" {
+		conf.Title = "API"
+	}
+	if conf.Version == "" {
+		conf.Version = "1.0.0"
+	}
+	if conf.Path == "" {
+		conf.Path = "/openapi.json"
+	}
+	if conf.FieldName == nil {
+		conf.FieldName = base.CaseToSnake
+	}
+
+	return &OpenAPI{conf: conf}
+}
+
+func (o *OpenAPI) add(op *Operation) {
+	o.lock.Lock()
+	defer o.lock.Unlock()
+
+	o.operations = append(o.operations, op)
+}
+
+// 注册文档路由
+func (o *OpenAPI) Routes(r gin.IRoutes) {
+	r.GET(o.conf.Path, func(c *gin.Context) {
+		c.JSON(http.StatusOK, o.Document())
+	})
+}
+
+// 生成文档
+func (o *OpenAPI) Document() map[string]any {
+	o.lock.Lock()
+	operations := append([]*Operation{}, o.operations...)
+	o.lock.Unlock()
+
+	g := newSchemaGenerator(o.conf.FieldName)
+
+	paths := map[string]map[string]any{}
+	for _, op := range operations {
+		path, params := openAPIPath(op.Path)
+		item, ok := paths[path]
+		if !ok {
+			item = map[string]any{}
+			paths[path] = item
+		}
+		item[strings.ToLower(op.Method)] = o.operation(g, op, params)
+	}
+
+	g.schemas["Error"] = map[string]any{
+		"type": "object",
+		"properties": map[string]any{
+			"code":     map[string]any{"type": "integer", "format": "int32"},
+			"msg":      map[string]any{"type": "string"},
+			"trace_id": map[string]any{"type": "string", "description": "只在发生恐慌时返回"},
+		},
+		"required": []string{"code", "msg"},
+	}
+
+	doc := map[string]any{
+		"openapi": "3.1.0",
+		"info": map[string]any{
+			"title":       o.conf.Title,
+			"version":     o.conf.Version,
+			"description": o.conf.Description,
+		},
+		"paths":      paths,
+		"components": map[string]any{"schemas": g.schemas},
+	}
+	if len(o.conf.Servers) > 0 {
+		servers := []map[string]any{}
+		for _, s := range o.conf.Servers {
+			servers = append(servers, map[string]any{"url": s})
+		}
+		doc["servers"] = servers
+	}
+	return doc
+}
+
+func (o *OpenAPI) operation(g *schemaGenerator, op *Operation, params []string) map[string]any {
+	// 响应统一为 {"code": 200, "data": ...}，见 JsonHandlerIO
+	envelope := map[string]any{
+		"type": "object",
+		"properties": map[string]any{
+			"code": map[string]any{"type": "integer", "format": "int32", "const": http.StatusOK},
+		},
+		"required": []string{"code"},
+	}
+	if op.Output != nil {
+		envelope["properties"].(map[string]any)["data"] = g.schema(op.Output)
+	}
+
+	errorResponse := map[string]any{
+		"description": "错误，code 与 HTTP 状态码相同",
+		"content": map[string]any{
+			"application/json": map[string]any{"schema": map[string]any{"$ref": "#/components/schemas/Error"}},
+		},
+	}
+
+	result := map[string]any{
+		"responses": map[string]any{
+			"200": map[string]any{
+				"description": "成功",
+				"content":     map[string]any{"application/json": map[string]any{"schema": envelope}},
+			},
+			"default": errorResponse,
+		},
+	}
+	if op.OperationID != "" {
+		result["operationId"] = op.OperationID
+	}
+	if op.Summary != "" {
+		result["summary"] = op.Summary
+	}
+	if op.Description != "" {
+		result["description"] = op.Description
+	}
+	if len(op.Tags) > 0 {
+		result["tags"] = op.Tags
+	}
+	if op.Deprecated {
+		result["deprecated"] = true
+	}
+
+	parameters := []map[string]any{}
+	for _, p := range params {
+		parameters = append(parameters, map[string]any{
+			"name":     p,
+			"in":       "path",
+			"required": true,
+			"schema":   map[string]any{"type": "string"},
+		})
+	}
+	for _, p := range op.Query {
+		parameters = append(parameters, map[string]any{
+			"name":   p,
+			"in":     "query",
+			"schema": map[string]any{"type": "string"},
+		})
+	}
+	if len(parameters) > 0 {
+		result["parameters"] = parameters
+	}
+
+	if op.Input != nil {
+		result["requestBody"] = map[string]any{
+			"required": true,
+			"content":  map[string]any{"application/json": map[string]any{"schema": g.schema(op.Input)}},
+		}
+	}
+
+	return result
+}
+
+// /users/:id/*path 改为 /users/{id}/{path}
+func openAPIPath(path string) (string, []string) {
+	params := []string{}
+	for _, m := range pathParamRegexp.FindAllStringSubmatch(path, -1) {
+		params = append(params, m[1])
+	}
+	return pathParamRegexp.ReplaceAllString(path, "{$1}"), params
+}
+
+// 包装 gin 的路由，通过 HandleIO、HandleI、HandleO 注册的路由会生成文档，也可以直接使用 gin 的方法注册不生成文档的路由
+type Router struct {
+	gin.IRouter
+
+	doc      *OpenAPI
+	basePath string
+	opts     []RouteOption // 组内所有路由的默认选项
+}
+
+func (o *OpenAPI) Router(r gin.IRouter) *Router {
+	basePath := "/"
+	if g, ok := r.(interface{ BasePath() string }); ok {
+		basePath = g.BasePath()
+	}
+
+	return &Router{IRouter: r, doc: o, basePath: basePath}
+}
+
+func (r *Router) Group(relativePath string, handlers ...gin.HandlerFunc) *Router {
+	return &Router{
+		IRouter:  r.IRouter.Group(relativePath, handlers...),
+		doc:      r.doc,
+		basePath: joinPaths(r.basePath, relativePath),
+		opts:     append([]RouteOption{}, r.opts...),
+	}
+}
+
+func (r *Router) handle(method string, relativePath string, input reflect.Type, output reflect.Type, handler gin.HandlerFunc, opts []RouteOption) {
+	op := &Operation{
+		Method: method,
+		Path:   joinPaths(r.basePath, relativePath),
+		Input:  input,
+		Output: output,
+	}
+	for _, opt := range append(append([]RouteOption{}, r.opts...), opts...) {
+		opt(op)
+	}
+
+	r.doc.add(op)
+	r.IRouter.Handle(method, relativePath, handler)
+}
+
+func joinPaths(absolutePath string, relativePath string) string {
+	if relativePath == "" {
+		return absolutePath
+	}
+
+	path := strings.TrimRight(absolutePath, "/") + "/" + strings.TrimLeft(relativePath, "/")
+	if !strings.HasSuffix(relativePath, "/") {
+		path = strings.TrimRight(path, "/")
+	}
+	if path == "" {
+		path = "/"
+	}
+	return path
+}
+
+// 注册有请求体和响应数据的路由，见 Route
+func HandleIO[I any, O any](r *Router, method string, path string, handler func(ctx context.Context, im I) (O, error), opts ...RouteOption) {
+	Route(r, method, path, handler, opts...)
+}
+
+// 注册有请求体、没有响应数据的路由
+func HandleI[I any](r *Router, method string, path string, handler func(ctx context.Context, im I) error, opts ...RouteOption) {
+	Route(r, method, path, func(ctx context.Context, im I) (Empty, error) {
+		return Empty{}, handler(ctx, im)
+	}, opts...)
+}
+
+// 注册没有请求体、有响应数据的路由
+func HandleO[O any](r *Router, method string, path string, handler func(ctx context.Context) (O, error), opts ...RouteOption) {
+	Route(r, method, path, func(ctx context.Context, _ Empty) (O, error) {
+		return handler(ctx)
+	}, opts...)
+}
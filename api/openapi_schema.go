@@ -0,0 +1,269 @@
+package api
+
+import (
+	"encoding/json"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	timeType       = reflect.TypeOf(time.Time{})
+	durationType   = reflect.TypeOf(time.Duration(0))
+	rawMessageType = reflect.TypeOf(json.RawMessage{})
+
+	schemaPackageRegexp = regexp.MustCompile(`[\w.\-]+/`)
+	schemaNameRegexp    = regexp.MustCompile(`[^A-Za-z0-9_]+`)
+)
+
+// 根据 Go 类型生成 JSON Schema（OpenAPI 3.1），具名结构体放到 components/schemas 中
+type schemaGenerator struct {
+	fieldName func(string) string
+
+	schemas map[string]map[string]any
+	names   map[reflect.Type]string
+}
+
+func newSchemaGenerator(fieldName func(string) string) *schemaGenerator {
+	return &schemaGenerator{
+		fieldName: fieldName,
+		schemas:   map[string]map[string]any{},
+		names:     map[reflect.Type]string{},
+	}
+}
+
+func (g *schemaGenerator) schema(t reflect.Type) map[string]any {
+	nullable := false
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+		nullable = true
+	}
+
+	s := g.typeSchema(t)
+	if nullable {
+		if typ, ok := s["type"].(string); ok {
+			s["type"] = []string{typ, "null"}
+		}
+	}
+	return s
+}
+
+func (g *schemaGenerator) typeSchema(t reflect.Type) map[string]any {
+	switch t {
+	case timeType:
+		return map[string]any{"type": "string", "format": "date-time"}
+	case durationType:
+		return map[string]any{"type": "integer", "format": "int64", "description": "纳秒"}
+	case rawMessageType:
+		return map[string]any{}
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}
+	case reflect.Int8, reflect.Int16, reflect.Int32, reflect.Uint8, reflect.Uint16:
+		return map[string]any{"type": "integer", "format": "int32"}
+	case reflect.Int, reflect.Int64, reflect.Uint, reflect.Uint32, reflect.Uint64:
+		return map[string]any{"type": "integer", "format": "int64"}
+	case reflect.Float32:
+		return map[string]any{"type": "number", "format": "float"}
+	case reflect.Float64:
+		return map[string]any{"type": "number", "format": "double"}
+	case reflect.String:
+		return map[string]any{"type": "string"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return map[string]any{"type": "string", "format": "byte"}
+		}
+		return map[string]any{"type": "array", "items": g.schema(t.Elem())}
+	case reflect.Map:
+		return map[string]any{"type": "object", "additionalProperties": g.schema(t.Elem())}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return g.structSchema(t)
+		}
+		return map[string]any{"$ref": "#/components/schemas/" + g.structName(t)}
+	default:
+		// interface 等无法确定类型
+		return map[string]any{}
+	}
+}
+
+// 注册具名结构体，返回其在 components/schemas 中的名称
+func (g *schemaGenerator) structName(t reflect.Type) string {
+	if name, ok := g.names[t]; ok {
+		return name
+	}
+
+	name := schemaName(t)
+	for i := 2; ; i++ {
+		if _, ok := g.schemas[name]; !ok {
+			break
+		}
+		name = schemaName(t) + strconv.Itoa(i)
+	}
+
+	// 先占位，避免递归类型无限展开
+	g.names[t] = name
+	g.schemas[name] = map[string]any{}
+	g.schemas[name] = g.structSchema(t)
+
+	return name
+}
+
+// Page[sgo-api/api.User] 改为 Page_api_User
+func schemaName(t reflect.Type) string {
+	name := schemaPackageRegexp.ReplaceAllString(t.Name(), "")
+	return strings.Trim(schemaNameRegexp.ReplaceAllString(name, "_"), "_")
+}
+
+func (g *schemaGenerator) structSchema(t reflect.Type) map[string]any {
+	properties := map[string]any{}
+	required := []string{}
+	g.fields(t, properties, &required)
+
+	s := map[string]any{"type": "object", "properties": properties}
+	if len(required) > 0 {
+		s["required"] = required
+	}
+	return s
+}
+
+func (g *schemaGenerator) fields(t reflect.Type, properties map[string]any, required *[]string) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+
+		tag, hasTag := f.Tag.Lookup("json")
+		tagParts := strings.Split(tag, ",")
+		if tagParts[0] == "-" && len(tagParts) == 1 {
+			continue
+		}
+
+		// 匿名结构体字段没有指定名称时，字段提升到外层
+		if f.Anonymous && (!hasTag || tagParts[0] == "") {
+			ft := f.Type
+			if ft.Kind() == reflect.Pointer {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct {
+				g.fields(ft, properties, required)
+				continue
+			}
+		}
+		if !f.IsExported() {
+			continue
+		}
+
+		name := tagParts[0]
+		if name == "" {
+			name = g.fieldName(f.Name)
+		}
+
+		s := g.schema(f.Type)
+		rules := fieldRules(f)
+		if _, ok := rules["required"]; ok {
+			*required = append(*required, name)
+		}
+		applyRules(s, f.Type, rules)
+
+		if desc := f.Tag.Get("description"); desc != "" {
+			s["description"] = desc
+		}
+
+		properties[name] = s
+	}
+}
+
+// 读取 binding 和 validate 标签中的校验规则
+func fieldRules(f reflect.StructField) map[string]string {
+	rules := map[string]string{}
+	for _, key := range []string{"binding", "validate"} {
+		for _, rule := range strings.Split(f.Tag.Get(key), ",") {
+			if rule == "" || rule == "dive" {
+				continue
+			}
+			k, v, _ := strings.Cut(rule, "=")
+			rules[k] = v
+		}
+	}
+	return rules
+}
+
+func applyRules(s map[string]any, t reflect.Type, rules map[string]string) {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	var minKey, maxKey string
+	switch t.Kind() {
+	case reflect.String:
+		minKey, maxKey = "minLength", "maxLength"
+	case reflect.Slice, reflect.Array, reflect.Map:
+		minKey, maxKey = "minItems", "maxItems"
+		if t.Kind() == reflect.Map {
+			minKey, maxKey = "minProperties", "maxProperties"
+		}
+	default:
+		minKey, maxKey = "minimum", "maximum"
+	}
+
+	number := func(v string) (any, bool) {
+		if i, err := strconv.ParseInt(v, 10, 64); err == nil {
+			return i, true
+		}
+		if f, err := strconv.ParseFloat(v, 64); err == nil {
+			return f, true
+		}
+		return nil, false
+	}
+
+	for k, v := range rules {
+		switch k {
+		case "min", "gte":
+			if n, ok := number(v); ok {
+				s[minKey] = n
+			}
+		case "max", "lte":
+			if n, ok := number(v); ok {
+				s[maxKey] = n
+			}
+		case "len":
+			if n, ok := number(v); ok {
+				s[minKey] = n
+				s[maxKey] = n
+			}
+		case "gt":
+			if n, ok := number(v); ok && minKey == "minimum" {
+				s["exclusiveMinimum"] = n
+			}
+		case "lt":
+			if n, ok := number(v); ok && maxKey == "maximum" {
+				s["exclusiveMaximum"] = n
+			}
+		case "oneof":
+			enum := []any{}
+			for _, e := range strings.Fields(v) {
+				if n, ok := number(e); ok && minKey == "minimum" {
+					enum = append(enum, n)
+				} else {
+					enum = append(enum, strings.Trim(e, "'"))
+				}
+			}
+			s["enum"] = enum
+		case "email":
+			s["format"] = "email"
+		case "url", "uri":
+			s["format"] = "uri"
+		case "uuid", "uuid4":
+			s["format"] = "uuid"
+		case "ip", "ipv4":
+			s["format"] = "ipv4"
+		case "ipv6":
+			s["format"] = "ipv6"
+		case "datetime":
+			s["format"] = "date-time"
+		}
+	}
+}
@@ -0,0 +1,95 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"reflect"
+	"sgo-api/base"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/samber/oops"
+)
+
+// 从查询参数读取列表参数，并按规则校验：
+//   - page、page_size、cursor
+//   - sort=-created_at,name，- 表示倒序
+//   - filter=status:eq:active，可以有多个，见 base.ParseFilter
+func BindPageQuery(c *gin.Context, rule base.PageRule) (base.PageQuery, error) {
+	q := base.PageQuery{
+		Cursor: c.Query("cursor"),
+		Sorts:  base.ParseSorts(c.Query("sort")),
+	}
+
+	for _, key := range []string{"page", "page_size"} {
+		v := c.Query(key)
+		if v == "" {
+			continue
+		}
+
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			return q, base.NewBadRequestErrorf("%v 不合法", key)
+		}
+		if key == "page" {
+			q.Page = n
+		} else {
+			q.PageSize = n
+		}
+	}
+
+	for _, expr := range c.QueryArray("filter") {
+		f, err := base.ParseFilter(expr)
+		if err != nil {
+			return q, oops.Wrap(err)
+		}
+		q.Filters = append(q.Filters, f)
+	}
+
+	q, err := rule.Normalize(q)
+	if err != nil {
+		return q, oops.Wrap(err)
+	}
+	return q, nil
+}
+
+// 列表处理器，读取并校验列表参数后调用 handler
+func PageHandler[T any](rule base.PageRule, handler func(ctx context.Context, q base.PageQuery) (base.Page[T], error)) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		q, err := BindPageQuery(c, rule)
+		if err != nil {
+			c.Error(oops.Wrap(err))
+			return
+		}
+
+		JsonTypedHandlerO(c, func(ctx context.Context) (base.Page[T], error) {
+			return handler(ctx, q)
+		})
+	}
+}
+
+// 一行注册 GET 列表路由，r 为 *Router 时同时生成文档
+//
+//	api.RoutePage(r, "/users", rule, func(ctx context.Context, q base.PageQuery) (base.Page[User], error) {
+//		return db.Paginate[User](tx.WithContext(ctx), q, rule)
+//	})
+func RoutePage[T any](r gin.IRoutes, path string, rule base.PageRule, handler func(ctx context.Context, q base.PageQuery) (base.Page[T], error), opts ...RouteOption) {
+	h := PageHandler(rule, handler)
+
+	router, ok := r.(*Router)
+	if !ok {
+		r.GET(path, h)
+		return
+	}
+
+	query := []string{"page_size", "sort", "filter"}
+	if rule.CursorField != "" {
+		query = append(query, "cursor")
+	} else {
+		query = append(query, "page")
+	}
+	opts = append([]RouteOption{func(op *Operation) {
+		op.Query = query
+	}}, opts...)
+	router.handle(http.MethodGet, path, nil, reflect.TypeFor[base.Page[T]](), h, opts)
+}
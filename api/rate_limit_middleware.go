@@ -0,0 +1,243 @@
+package api
+
+import (
+	"context"
+	"math"
+	"sgo-api/base"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	RateLimitTokenBucket   = "token_bucket"   // 令牌桶，允许一定的突发
+	RateLimitSlidingWindow = "sliding_window" // 滑动窗口（按前后两个固定窗口加权估算）
+
+	RateLimitKeyIP     = "ip"     // 按客户端 IP
+	RateLimitKeyRoute  = "route"  // 按路由
+	RateLimitKeyHeader = "header" // 按请求头，比如 API Key
+)
+
+type RateLimitConfig struct {
+	Algorithm string        // 限流算法，见 RateLimitTokenBucket 等。默认为令牌桶
+	Limit     int           // 每个窗口允许的请求数，令牌桶为每个窗口补充的令牌数
+	Window    time.Duration // 窗口大小。默认为 1s
+	Burst     int           // 令牌桶容量。默认等于 Limit
+
+	KeyBy   string                      // 限流维度，见 RateLimitKeyIP 等。默认按客户端 IP
+	Header  string                      // 按请求头限流时使用的请求头。默认为 X-Api-Key
+	KeyFunc func(c *gin.Context) string // 自定义限流维度，优先级最高。返回空字符串时不限流
+
+	Store RateLimitStore            // 限流状态存储。默认为内存，多实例共享限流时可替换
+	Skip  func(c *gin.Context) bool // 跳过限流
+}
+
+// 限流状态，各算法共用
+type RateLimitState struct {
+	Tokens float64   `json:"tokens"` // 令牌桶：剩余令牌
+	Last   time.Time `json:"last"`   // 令牌桶：上次补充令牌的时间
+
+	WindowStart time.Time `json:"window_start"` // 滑动窗口：当前窗口的开始时间
+	Prev        int       `json:"prev"`         // 滑动窗口：上个窗口的请求数
+	Curr        int       `json:"curr"`         // 滑动窗口：当前窗口的请求数
+}
+
+// 限流状态存储
+type RateLimitStore interface {
+	// 原子地读取并更新 key 对应的状态，不存在时 state 为零值。ttl 为状态的过期时间
+	Update(ctx context.Context, key string, ttl time.Duration, update func(state *RateLimitState)) error
+}
+
+func RateLimitMiddleware(log base.Logger, conf RateLimitConfig) gin.HandlerFunc {
+	log = log.WithTag("GIN")
+
+	if conf.Algorithm == "" {
+		conf.Algorithm = RateLimitTokenBucket
+	}
+	if conf.Limit <= 0 {
+		conf.Limit = 100
+	}
+	if conf.Window <= 0 {
+		conf.Window = time.Second
+	}
+	if conf.Burst <= 0 {
+		conf.Burst = conf.Limit
+	}
+	if conf.Header == "" {
+		conf.Header = "X-Api-Key"
+	}
+	if conf.Store == nil {
+		conf.Store = NewMemoryRateLimitStore()
+	}
+
+	// 状态过期后视为重新开始，过期时间需覆盖令牌桶从空到满的时间
+	ttl := 2 * conf.Window
+	take := takeTokenBucket
+	if conf.Algorithm == RateLimitSlidingWindow {
+		take = takeSlidingWindow
+	} else if fill := conf.Window * time.Duration(conf.Burst) / time.Duration(conf.Limit); fill > ttl {
+		ttl = fill
+	}
+
+	return func(c *gin.Context) {
+		if conf.Skip != nil && conf.Skip(c) {
+			c.Next()
+			return
+		}
+
+		key := rateLimitKey(c, conf)
+		if key == "" {
+			c.Next()
+			return
+		}
+
+		var allowed bool
+		var remaining int
+		var retryAfter time.Duration
+		err := conf.Store.Update(c.Request.Context(), key, ttl, func(state *RateLimitState) {
+			allowed, remaining, retryAfter = take(state, conf, time.Now())
+		})
+		if err != nil {
+			// 存储出错时放行，避免限流影响正常服务
+			getLogger(c, log).Errorf("限流出错：%+v", err)
+			c.Next()
+			return
+		}
+
+		c.Header("X-RateLimit-Limit", strconv.Itoa(conf.Limit))
+		c.Header("X-RateLimit-Remaining", strconv.Itoa(remaining))
+
+		if !allowed {
+			seconds := int(math.Ceil(retryAfter.Seconds()))
+			if seconds < 1 {
+				seconds = 1
+			}
+
+			c.Header("Retry-After", strconv.Itoa(seconds))
+			c.Error(base.NewTooManyRequestsErrorf("请求过于频繁，请 %d 秒后重试", seconds))
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
+
+func rateLimitKey(c *gin.Context, conf RateLimitConfig) string {
+	if conf.KeyFunc != nil {
+		return conf.KeyFunc(c)
+	}
+
+	switch conf.KeyBy {
+	case RateLimitKeyRoute:
+		return "route:" + c.Request.Method + " " + c.FullPath()
+	case RateLimitKeyHeader:
+		if v := c.GetHeader(conf.Header); v != "" {
+			return "header:" + v
+		}
+		return ""
+	default:
+		return "ip:" + GetRequest(c).IP
+	}
+}
+
+func takeTokenBucket(state *RateLimitState, conf RateLimitConfig, now time.Time) (bool, int, time.Duration) {
+	rate := float64(conf.Limit) / conf.Window.Seconds() // 每秒补充的令牌数
+
+	if state.Last.IsZero() {
+		state.Tokens = float64(conf.Burst)
+	} else if elapsed := now.Sub(state.Last).Seconds(); elapsed > 0 {
+		state.Tokens = math.Min(float64(conf.Burst), state.Tokens+elapsed*rate)
+	}
+	state.Last = now
+
+	if state.Tokens >= 1 {
+		state.Tokens--
+		return true, int(state.Tokens), 0
+	}
+
+	return false, 0, time.Duration((1 - state.Tokens) / rate * float64(time.Second))
+}
+
+func takeSlidingWindow(state *RateLimitState, conf RateLimitConfig, now time.Time) (bool, int, time.Duration) {
+	start := now.Truncate(conf.Window)
+	if !state.WindowStart.Equal(start) {
+		if state.WindowStart.Add(conf.Window).Equal(start) {
+			state.Prev = state.Curr
+		} else {
+			state.Prev = 0
+		}
+		state.Curr = 0
+		state.WindowStart = start
+	}
+
+	// 上个窗口按剩余比例计入
+	elapsed := float64(now.Sub(start)) / float64(conf.Window)
+	count := float64(state.Prev)*(1-elapsed) + float64(state.Curr)
+
+	if count+1 <= float64(conf.Limit) {
+		state.Curr++
+		return true, int(float64(conf.Limit) - count - 1), 0
+	}
+
+	// 估算何时能放行：当前窗口已满则等到下个窗口，否则等上个窗口的权重下降
+	untilNext := start.Add(conf.Window).Sub(now)
+	if state.Prev == 0 || state.Curr+1 > conf.Limit {
+		return false, 0, untilNext
+	}
+
+	need := 1 - float64(conf.Limit-state.Curr-1)/float64(state.Prev)
+	wait := time.Duration(need*float64(conf.Window)) - now.Sub(start)
+	if wait <= 0 || wait > untilNext {
+		wait = untilNext
+	}
+	return false, 0, wait
+}
+
+// 内存限流状态存储
+type MemoryRateLimitStore struct {
+	lock    sync.Mutex
+	states  map[string]*memoryRateLimitState
+	updates int
+}
+
+type memoryRateLimitState struct {
+	state    RateLimitState
+	expireAt time.Time
+}
+
+func NewMemoryRateLimitStore() *MemoryRateLimitStore {
+	return &MemoryRateLimitStore{
+		states: map[string]*memoryRateLimitState{},
+	}
+}
+
+func (s *MemoryRateLimitStore) Update(ctx context.Context, key string, ttl time.Duration, update func(state *RateLimitState)) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	now := time.Now()
+
+	// 定期清理过期状态
+	s.updates++
+	if s.updates%1024 == 0 {
+		for k, v := range s.states {
+			if now.After(v.expireAt) {
+				delete(s.states, k)
+			}
+		}
+	}
+
+	v, ok := s.states[key]
+	if !ok || now.After(v.expireAt) {
+		v = &memoryRateLimitState{}
+		s.states[key] = v
+	}
+
+	update(&v.state)
+	v.expireAt = now.Add(ttl)
+
+	return nil
+}
@@ -1,79 +1,129 @@
-package api
-
-import (
-	"bytes"
-	"io"
-	"sgo-api/base"
-	"time"
-
-	"github.com/gin-gonic/gin"
-	"github.com/samber/oops"
-)
-
-const (
-	requestContextKey = "sgo-api.request"
-)
-
-type Request struct {
-	IP        string
-	Method    string
-	Path      string
-	StartTime time.Time
-
-	Error error
-
-	body []byte
-}
-
-func newRequest(c *gin.Context) (req *Request) {
-	req = &Request{}
-
-	defer func() {
-		if err := base.Recover(recover()); err != nil {
-			req.Error = oops.Wrapf(err, "读取请求发生恐慌")
-		}
-	}()
-
-	req.IP = c.ClientIP()
-	req.StartTime = time.Now()
-	req.Method = c.Request.Method
-
-	path := c.Request.URL.Path
-	raw := c.Request.URL.RawQuery
-	if raw != "" {
-		path = path + "?" + raw
-	}
-	req.Path = path
-
-	if body, err := io.ReadAll(c.Request.Body); err != nil {
-		req.Error = oops.Wrapf(err, "读取请求 Body 出错")
-	} else {
-		if body == nil {
-			body = []byte{}
-		}
-		c.Request.Body = io.NopCloser(bytes.NewReader(body))
-		req.body = body
-	}
-
-	return
-}
-
-func (req *Request) Body() ([]byte, error) {
-	if req.Error != nil {
-		return nil, oops.Wrap(req.Error)
-	}
-
-	return req.body, nil
-}
-
-func GetRequest(c *gin.Context) *Request {
-	if v, ok := c.Get(requestContextKey); ok {
-		if req, ok := v.(*Request); ok {
-			return req
-		}
-	}
-
-	req := newRequest(c)
-	c.Set(requestContextKey, req)
-	return req
-}
+package api
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"sgo-api/base"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/samber/oops"
+)
+
+const (
+	requestContextKey = "sgo-api.request"
+)
+
+// 请求的元数据，日志、指标、审计等共用
+type Request struct {
+	IP            string
+	Method        string
+	Path          string // 包含查询参数的实际路径
+	Route         string // 匹配的路由模板，比如 /users/:id。未匹配到路由时为空
+	Header        http.Header
+	UserAgent     string
+	ContentType   string
+	ContentLength int64 // 未知时为 -1
+	StartTime     time.Time
+
+	Principal *Principal // 认证主体，未认证时为 nil
+
+	BodySkipped bool // 请求体是流式的（比如文件上传），没有读取，Body 返回空
+
+	// 响应信息，请求结束后（经过 LogMiddleware）才有值
+	Status   int
+	Size     int // 响应体大小（字节），未写入时为 -1
+	EndTime  time.Time
+	Latency  time.Duration
+	Finished bool
+
+	Error error
+
+	body []byte
+}
+
+func newRequest(c *gin.Context) (req *Request) {
+	req = &Request{}
+
+	defer func() {
+		if err := base.Recover(recover()); err != nil {
+			req.Error = oops.Wrapf(err, "读取请求发生恐慌")
+		}
+	}()
+
+	req.IP = c.ClientIP()
+	req.StartTime = time.Now()
+	req.Method = c.Request.Method
+	req.Route = c.FullPath()
+	req.Header = c.Request.Header
+	req.UserAgent = c.Request.UserAgent()
+	req.ContentType = c.ContentType()
+	req.ContentLength = c.Request.ContentLength
+	req.Principal = GetPrincipal(c)
+
+	path := c.Request.URL.Path
+	raw := c.Request.URL.RawQuery
+	if raw != "" {
+		path = path + "?" + raw
+	}
+	req.Path = path
+
+	// 文件上传等流式请求体不读取，交给处理器流式处理
+	if req.ContentType == gin.MIMEMultipartPOSTForm || req.ContentType == "application/octet-stream" {
+		req.BodySkipped = true
+		req.body = []byte{}
+	} else if body, err := io.ReadAll(c.Request.Body); err != nil {
+		req.Error = oops.Wrapf(err, "读取请求 Body 出错")
+	} else {
+		if body == nil {
+			body = []byte{}
+		}
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+		req.body = body
+	}
+
+	return
+}
+
+func (req *Request) Body() ([]byte, error) {
+	if req.Error != nil {
+		return nil, oops.Wrap(req.Error)
+	}
+
+	return req.body, nil
+}
+
+// 记录响应信息
+func (req *Request) finish(c *gin.Context) {
+	req.Status = c.Writer.Status()
+	req.Size = c.Writer.Size()
+	req.EndTime = time.Now()
+	req.Latency = req.EndTime.Sub(req.StartTime)
+	req.Finished = true
+
+	if p := GetPrincipal(c); p != nil {
+		req.Principal = p
+	}
+}
+
+// 获取请求的元数据，首次调用时读取请求
+func GetRequest(c *gin.Context) *Request {
+	if req := lookupRequest(c); req != nil {
+		return req
+	}
+
+	req := newRequest(c)
+	c.Set(requestContextKey, req)
+	return req
+}
+
+// 获取已经创建的请求元数据，没有时返回 nil
+func lookupRequest(c *gin.Context) *Request {
+	if v, ok := c.Get(requestContextKey); ok {
+		if req, ok := v.(*Request); ok {
+			return req
+		}
+	}
+	return nil
+}
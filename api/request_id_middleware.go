@@ -0,0 +1,56 @@
+package api
+
+import (
+	"sgo-api/base"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	requestIDContextKey = "sgo-api.request_id"
+)
+
+type RequestIDConfig struct {
+	Header   string        // 请求头和响应头。默认为 X-Request-Id
+	Generate func() string // 生成请求 ID。默认为 base.NewNanoID
+}
+
+// 请求 ID 中间件，沿用客户端传入的请求 ID（需为可见 ASCII 且不超过 128 个字符），否则生成一个，并写入响应头
+func RequestIDMiddleware(conf RequestIDConfig) gin.HandlerFunc {
+	if conf.Header == "" {
+		conf.Header = "X-Request-Id"
+	}
+	if conf.Generate == nil {
+		conf.Generate = base.NewNanoID
+	}
+
+	return func(c *gin.Context) {
+		id := c.GetHeader(conf.Header)
+		if !validRequestID(id) {
+			id = conf.Generate()
+		}
+
+		c.Set(requestIDContextKey, id)
+		c.Header(conf.Header, id)
+
+		c.Next()
+	}
+}
+
+// 获取请求 ID，未经过 RequestIDMiddleware 时返回空字符串
+func GetRequestID(c *gin.Context) string {
+	return c.GetString(requestIDContextKey)
+}
+
+func validRequestID(id string) bool {
+	if id == "" || len(id) > 128 {
+		return false
+	}
+
+	for i := 0; i < len(id); i++ {
+		if id[i] < 0x21 || id[i] > 0x7e {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,73 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 安全响应头，字符串配置为空时使用默认值，为 - 时不设置
+type SecurityHeadersConfig struct {
+	HSTSMaxAge            time.Duration // HSTS 的有效期，只对 HTTPS 请求设置。默认为 0，即不设置
+	HSTSIncludeSubdomains bool
+	HSTSPreload           bool
+
+	ContentTypeOptions    string // X-Content-Type-Options。默认为 nosniff
+	FrameOptions          string // X-Frame-Options。默认为 DENY
+	ContentSecurityPolicy string // Content-Security-Policy。默认为 default-src 'none'; frame-ancestors 'none'，适用于纯 API
+	ReferrerPolicy        string // Referrer-Policy。默认为 no-referrer
+}
+
+func SecurityHeadersMiddleware(conf SecurityHeadersConfig) gin.HandlerFunc {
+	headers := map[string]string{}
+	set := func(name string, value string, defaultValue string) {
+		if value == "" {
+			value = defaultValue
+		}
+		if value != "-" {
+			headers[name] = value
+		}
+	}
+	set("X-Content-Type-Options", conf.ContentTypeOptions, "nosniff")
+	set("X-Frame-Options", conf.FrameOptions, "DENY")
+	set("Content-Security-Policy", conf.ContentSecurityPolicy, "default-src 'none'; frame-ancestors 'none'")
+	set("Referrer-Policy", conf.ReferrerPolicy, "no-referrer")
+
+	hsts := ""
+	if conf.HSTSMaxAge > 0 {
+		hsts = fmt.Sprintf("max-age=%d", int(conf.HSTSMaxAge.Seconds()))
+		if conf.HSTSIncludeSubdomains {
+			hsts += "; includeSubDomains"
+		}
+		if conf.HSTSPreload {
+			hsts += "; preload"
+		}
+	}
+
+	return func(c *gin.Context) {
+		h := c.Writer.Header()
+		for k, v := range headers {
+			h.Set(k, v)
+		}
+
+		if hsts != "" && (c.Request.TLS != nil || c.GetHeader("X-Forwarded-Proto") == "https") {
+			h.Set("Strict-Transport-Security", hsts)
+		}
+
+		c.Next()
+	}
+}
+
+// 限制请求体大小，超出时读取请求体会得到 http.MaxBytesError，由 ErrorMiddleware 响应 413
+//   - 需要放在 LogMiddleware 之前，才能限制日志中间件读取请求体
+func BodyLimitMiddleware(limit int64) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if limit > 0 && c.Request.Body != nil {
+			c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, limit)
+		}
+
+		c.Next()
+	}
+}
@@ -0,0 +1,311 @@
+package api
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"errors"
+	"net"
+	"net/http"
+	"os"
+	"sgo-api/base"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/samber/oops"
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
+)
+
+type ListenerConfig struct {
+	Addr string     // host:port，或者 unix:/path/to.sock
+	TLS  *TLSConfig // 为 nil 时为 HTTP
+
+	ReadHeaderTimeout time.Duration // 读取请求头的超时时间，防止慢速攻击。默认为 10s
+	IdleTimeout       time.Duration // keep-alive 连接的空闲超时时间。默认为 120s
+}
+
+type TLSConfig struct {
+	CertFile string
+	KeyFile  string
+
+	// 客户端证书的 CA，不为空时校验客户端证书（mTLS），可以通过 GetClientCert 或 MTLSAuthenticator 获取客户端身份
+	ClientCAFile string
+	ClientAuth   tls.ClientAuthType // 默认为 RequireAndVerifyClientCert，需要同时支持无证书的客户端时使用 VerifyClientCertIfGiven
+
+	MinVersion     uint16        // 默认为 TLS 1.2
+	ReloadInterval time.Duration // 检查证书文件变化的间隔，变化后重新加载，不需要重启。默认为 10s
+}
+
+type AdminConfig struct {
+	Listener    ListenerConfig     // 一般只监听内网或本机，比如 127.0.0.1:9090
+	Diagnostics *DiagnosticsConfig // 诊断接口（pprof 等），为 nil 时不注册
+
+	// 注册管理接口，比如监控指标。Health 不为 nil 时也会注册健康检查
+	Extend func(r *gin.Engine)
+}
+
+// 证书热加载，证书或 CA 文件的修改时间变化时重新加载，加载失败时继续使用旧的
+type certReloader struct {
+	conf TLSConfig
+	log  base.Logger
+
+	lock    sync.RWMutex
+	config  *tls.Config
+	modTime map[string]time.Time
+}
+
+func newCertReloader(conf TLSConfig, log base.Logger) (*certReloader, error) {
+	if conf.MinVersion == 0 {
+		conf.MinVersion = tls.VersionTLS12
+	}
+	if conf.ReloadInterval <= 0 {
+		conf.ReloadInterval = 10 * time.Second
+	}
+	if conf.ClientCAFile != "" && conf.ClientAuth == tls.NoClientCert {
+		conf.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+
+	r := &certReloader{conf: conf, log: log}
+	if _, err := r.reload(); err != nil {
+		return nil, oops.Wrap(err)
+	}
+	return r, nil
+}
+
+func (r *certReloader) files() []string {
+	files := []string{r.conf.CertFile, r.conf.KeyFile}
+	if r.conf.ClientCAFile != "" {
+		files = append(files, r.conf.ClientCAFile)
+	}
+	return files
+}
+
+// 文件有变化时重新加载，返回是否重新加载
+func (r *certReloader) reload() (bool, error) {
+	modTime := map[string]time.Time{}
+	changed := r.modTime == nil
+	for _, f := range r.files() {
+		stat, err := os.Stat(f)
+		if err != nil {
+			return false, oops.Wrapf(err, "读取证书文件 %v 失败", f)
+		}
+		modTime[f] = stat.ModTime()
+		if !stat.ModTime().Equal(r.modTime[f]) {
+			changed = true
+		}
+	}
+	if !changed {
+		return false, nil
+	}
+
+	cert, err := tls.LoadX509KeyPair(r.conf.CertFile, r.conf.KeyFile)
+	if err != nil {
+		return false, oops.Wrapf(err, "加载证书失败")
+	}
+
+	config := &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   r.conf.MinVersion,
+		NextProtos:   []string{http2.NextProtoTLS, "http/1.1"},
+	}
+	if r.conf.ClientCAFile != "" {
+		pem, err := os.ReadFile(r.conf.ClientCAFile)
+		if err != nil {
+			return false, oops.Wrapf(err, "读取客户端 CA 失败")
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return false, oops.Errorf("客户端 CA %v 中没有证书", r.conf.ClientCAFile)
+		}
+		config.ClientCAs = pool
+		config.ClientAuth = r.conf.ClientAuth
+	}
+
+	r.lock.Lock()
+	r.config = config
+	r.modTime = modTime
+	r.lock.Unlock()
+
+	return true, nil
+}
+
+func (r *certReloader) watch(ctx context.Context) {
+	ticker := time.NewTicker(r.conf.ReloadInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if reloaded, err := r.reload(); err != nil {
+				r.log.Errorf("重新加载证书失败，继续使用旧证书：%+v", err)
+			} else if reloaded {
+				r.log.Infof("已重新加载证书：%v", r.conf.CertFile)
+			}
+		}
+	}
+}
+
+func (r *certReloader) tlsConfig() *tls.Config {
+	return &tls.Config{
+		MinVersion: r.conf.MinVersion,
+		NextProtos: []string{http2.NextProtoTLS, "http/1.1"},
+		GetConfigForClient: func(*tls.ClientHelloInfo) (*tls.Config, error) {
+			r.lock.RLock()
+			defer r.lock.RUnlock()
+			return r.config, nil
+		},
+	}
+}
+
+func listen(ctx context.Context, addr string) (net.Listener, error) {
+	lc := net.ListenConfig{}
+	if path, ok := strings.CutPrefix(addr, "unix:"); ok {
+		// 删除上次没有正常退出留下的 socket 文件，不是 socket 时报错，避免误删配置错误的路径上的文件
+		if stat, err := os.Lstat(path); err == nil {
+			if stat.Mode()&os.ModeSocket == 0 {
+				return nil, oops.Errorf("%v 已存在且不是 socket 文件", path)
+			}
+			if err := os.Remove(path); err != nil {
+				return nil, oops.Wrap(err)
+			}
+		} else if !errors.Is(err, os.ErrNotExist) {
+			return nil, oops.Wrap(err)
+		}
+		return lc.Listen(ctx, "unix", path)
+	}
+	return lc.Listen(ctx, "tcp", addr)
+}
+
+// 是否只能从本机访问：unix socket 或回环地址
+func isLocalAddr(addr string) bool {
+	if strings.HasPrefix(addr, "unix:") {
+		return true
+	}
+
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return false
+	}
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// 一个监听地址对应一个 http.Server
+type server struct {
+	conf ListenerConfig
+	srv  *http.Server
+	cert *certReloader
+}
+
+func newServer(conf ListenerConfig, handler http.Handler, h2cEnabled bool, log base.Logger) (*server, error) {
+	if conf.ReadHeaderTimeout <= 0 {
+		conf.ReadHeaderTimeout = 10 * time.Second
+	}
+	if conf.IdleTimeout <= 0 {
+		conf.IdleTimeout = 120 * time.Second
+	}
+
+	s := &server{conf: conf, srv: &http.Server{
+		Handler:           handler,
+		ReadHeaderTimeout: conf.ReadHeaderTimeout,
+		IdleTimeout:       conf.IdleTimeout,
+	}}
+
+	if conf.TLS != nil {
+		cert, err := newCertReloader(*conf.TLS, log)
+		if err != nil {
+			return nil, oops.Wrap(err)
+		}
+		s.cert = cert
+		s.srv.TLSConfig = cert.tlsConfig()
+	} else if h2cEnabled {
+		s.srv.Handler = h2c.NewHandler(handler, &http2.Server{})
+	}
+
+	return s, nil
+}
+
+// 监听并服务，出错时间隔 1s 重试，直到 Shutdown
+func (s *server) run(ctx context.Context, log base.Logger) {
+	if s.cert != nil {
+		go s.cert.watch(ctx)
+	}
+
+	for {
+		err := func() error {
+			l, err := listen(ctx, s.conf.Addr)
+			if err != nil {
+				return oops.Wrap(err)
+			}
+
+			if s.cert != nil {
+				log.Infof("监听 HTTPS：%v", s.conf.Addr)
+				return s.srv.ServeTLS(l, "", "")
+			}
+			log.Infof("监听 HTTP：%v", s.conf.Addr)
+			return s.srv.Serve(l)
+		}()
+		if errors.Is(err, http.ErrServerClosed) {
+			return
+		}
+		if ctx.Err() != nil {
+			return
+		}
+		log.Errorf("运行出错：%+v", err)
+		time.Sleep(time.Second)
+	}
+}
+
+func (s *server) shutdown(ctx context.Context, log base.Logger) {
+	if err := s.srv.Shutdown(ctx); err != nil {
+		log.Errorf("关闭 %v 出错：%+v", s.conf.Addr, err)
+	}
+}
+
+// mTLS 客户端证书中的身份
+type ClientCert struct {
+	Subject      string    `json:"subject"`
+	CommonName   string    `json:"common_name"`
+	Organization []string  `json:"organization"`
+	DNSNames     []string  `json:"dns_names"`
+	Emails       []string  `json:"emails"`
+	URIs         []string  `json:"uris"` // 比如 SPIFFE ID
+	SerialNumber string    `json:"serial_number"`
+	Fingerprint  string    `json:"fingerprint"` // SHA-256
+	NotAfter     time.Time `json:"not_after"`
+}
+
+// 获取已校验的客户端证书，不是 mTLS 连接或客户端没有提供证书时返回 nil
+func GetClientCert(c *gin.Context) *ClientCert {
+	state := c.Request.TLS
+	if state == nil || len(state.VerifiedChains) == 0 || len(state.VerifiedChains[0]) == 0 {
+		return nil
+	}
+
+	cert := state.VerifiedChains[0][0]
+	fingerprint := sha256.Sum256(cert.Raw)
+	cc := &ClientCert{
+		Subject:      cert.Subject.String(),
+		CommonName:   cert.Subject.CommonName,
+		Organization: cert.Subject.Organization,
+		DNSNames:     cert.DNSNames,
+		Emails:       cert.EmailAddresses,
+		SerialNumber: cert.SerialNumber.String(),
+		Fingerprint:  hex.EncodeToString(fingerprint[:]),
+		NotAfter:     cert.NotAfter,
+	}
+	for _, u := range cert.URIs {
+		cc.URIs = append(cc.URIs, u.String())
+	}
+	return cc
+}
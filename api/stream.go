@@ -0,0 +1,254 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"sgo-api/base"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/render"
+	"github.com/samber/oops"
+)
+
+type SSEConfig struct {
+	Heartbeat time.Duration // 心跳间隔，防止代理断开空闲连接。默认为 15s
+	Retry     time.Duration // 建议客户端的重连间隔。默认为 0，即不设置
+}
+
+type SSEEvent struct {
+	ID    string
+	Event string        // 事件类型，为空时客户端按 message 处理
+	Data  any           // string 和 []byte 原样发送，其他类型编码为 JSON
+	Retry time.Duration // 为 0 时不设置
+}
+
+// 流式响应的公共部分：加锁写入、刷新、统计
+type stream struct {
+	c    *gin.Context
+	ctx  context.Context // 去掉了 TimeoutMiddleware 的截止时间，只在客户端断开时结束
+	lock sync.Mutex
+
+	start  time.Time
+	count  int
+	bytes  int64
+	closed bool
+}
+
+func newStream(c *gin.Context, contentType string) *stream {
+	SkipResponseCapture(c)
+
+	h := c.Writer.Header()
+	h.Set("Content-Type", contentType)
+	h.Set("Cache-Control", "no-cache")
+	h.Set("X-Accel-Buffering", "no") // 关闭 Nginx 的缓冲
+	c.Status(http.StatusOK)
+	c.Writer.WriteHeaderNow()
+	c.Writer.Flush()
+
+	return &stream{c: c, ctx: withoutTimeout(c), start: time.Now()}
+}
+
+func (s *stream) write(data []byte, count bool) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if err := s.ctx.Err(); err != nil {
+		return oops.Wrapf(err, "客户端已断开")
+	}
+	if s.closed {
+		return oops.Errorf("流已关闭")
+	}
+
+	n, err := s.c.Writer.Write(data)
+	s.bytes += int64(n)
+	if err != nil {
+		return oops.Wrap(err)
+	}
+	s.c.Writer.Flush()
+
+	if count {
+		s.count++
+	}
+	return nil
+}
+
+func (s *stream) close(kind string) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.closed = true
+	setResponseSummary(s.c, fmt.Sprintf("[%v %d 条 %d bytes %v]", kind, s.count, s.bytes, time.Since(s.start)))
+}
+
+// Server-Sent Events 流
+type SSEStream struct {
+	*stream
+}
+
+// 客户端重连时带上的最后一个事件 ID
+func (s *SSEStream) LastEventID() string {
+	return s.c.GetHeader("Last-Event-ID")
+}
+
+func (s *SSEStream) Send(e SSEEvent) error {
+	buf := bytes.Buffer{}
+	if e.ID != "" {
+		buf.WriteString("id: " + strings.ReplaceAll(e.ID, "\n", "") + "\n")
+	}
+	if e.Event != "" {
+		buf.WriteString("event: " + strings.ReplaceAll(e.Event, "\n", "") + "\n")
+	}
+	if e.Retry > 0 {
+		buf.WriteString("retry: " + strconv.FormatInt(e.Retry.Milliseconds(), 10) + "\n")
+	}
+
+	var data []byte
+	switch d := e.Data.(type) {
+	case string:
+		data = []byte(d)
+	case []byte:
+		data = d
+	default:
+		var err error
+		if data, err = marshalJSON(d); err != nil {
+			return oops.Wrap(err)
+		}
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		buf.WriteString("data: " + strings.TrimSuffix(line, "\r") + "\n")
+	}
+	buf.WriteString("\n")
+
+	return s.write(buf.Bytes(), true)
+}
+
+// 响应 Server-Sent Events，handler 返回或客户端断开（ctx 结束）时结束，不受 TimeoutMiddleware 的截止时间限制
+//   - 定时发送注释行作为心跳
+//   - handler 返回错误时，发送 error 事件并记录日志
+//   - 日志只记录事件数量、字节数和时长，不记录内容
+func SSE(c *gin.Context, conf SSEConfig, handler func(ctx context.Context, s *SSEStream) error) {
+	if conf.Heartbeat <= 0 {
+		conf.Heartbeat = 15 * time.Second
+	}
+
+	s := &SSEStream{newStream(c, "text/event-stream")}
+	defer s.close("SSE")
+
+	if conf.Retry > 0 {
+		s.write([]byte("retry: "+strconv.FormatInt(conf.Retry.Milliseconds(), 10)+"\n\n"), false)
+	}
+
+	ctx, cancel := context.WithCancel(s.ctx)
+	done := make(chan struct{})
+	defer func() {
+		// 等待心跳结束，请求结束后 gin 会复用 Context
+		cancel()
+		<-done
+	}()
+
+	go func() {
+		defer close(done)
+
+		ticker := time.NewTicker(conf.Heartbeat)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if err := s.write([]byte(": ping\n\n"), false); err != nil {
+					return
+				}
+			}
+		}
+	}()
+
+	if err := handler(ctx, s); err != nil && ctx.Err() == nil {
+		getLogger(c, base.DefaultLogger()).Errorf("SSE 出错：%+v", err)
+		s.Send(SSEEvent{Event: "error", Data: gin.H{"code": http.StatusInternalServerError, "msg": err.Error()}})
+	}
+}
+
+// 带输入的 SSE 处理器，GET 请求从查询参数绑定输入
+func SSEHandler[I any](conf SSEConfig, handler func(ctx context.Context, im I, s *SSEStream) error) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var im I
+		if err := c.ShouldBind(&im); err != nil {
+			c.Error(oops.Wrap(err))
+			return
+		}
+
+		SSE(c, conf, func(ctx context.Context, s *SSEStream) error {
+			return handler(ctx, im, s)
+		})
+	}
+}
+
+// NDJSON 流，每行一个 JSON
+type NDJSONStream struct {
+	*stream
+}
+
+func (s *NDJSONStream) Send(v any) error {
+	data, err := marshalJSON(v)
+	if err != nil {
+		return oops.Wrap(err)
+	}
+	return s.write(append(data, '\n'), true)
+}
+
+// 响应 NDJSON（application/x-ndjson），handler 返回或客户端断开（ctx 结束）时结束，不受 TimeoutMiddleware 的截止时间限制
+//   - handler 返回错误时，最后一行为 {"code": 500, "msg": ...}，并记录日志
+//   - 日志只记录行数、字节数和时长，不记录内容
+func NDJSON(c *gin.Context, handler func(ctx context.Context, s *NDJSONStream) error) {
+	s := &NDJSONStream{newStream(c, "application/x-ndjson")}
+	defer s.close("NDJSON")
+
+	ctx := s.ctx
+	if err := handler(ctx, s); err != nil && ctx.Err() == nil {
+		getLogger(c, base.DefaultLogger()).Errorf("NDJSON 出错：%+v", err)
+		s.Send(gin.H{"code": http.StatusInternalServerError, "msg": err.Error()})
+	}
+}
+
+// 带输入的 NDJSON 处理器，按请求方法和 Content-Type 绑定输入（GET 为查询参数，POST JSON 为请求体）
+func NDJSONHandler[I any](handler func(ctx context.Context, im I, s *NDJSONStream) error) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var im I
+		if err := c.ShouldBind(&im); err != nil {
+			c.Error(oops.Wrap(err))
+			return
+		}
+
+		NDJSON(c, func(ctx context.Context, s *NDJSONStream) error {
+			return handler(ctx, im, s)
+		})
+	}
+}
+
+// 使用 gin 的 JSON 编码（编译时带 jsoniter 标签时为 jsoniter），与 c.JSON 的输出一致
+func marshalJSON(v any) ([]byte, error) {
+	w := &bufferResponseWriter{header: http.Header{}}
+	if err := (render.JSON{Data: v}).Render(w); err != nil {
+		return nil, oops.Wrap(err)
+	}
+	return w.Bytes(), nil
+}
+
+type bufferResponseWriter struct {
+	bytes.Buffer
+	header http.Header
+}
+
+func (w *bufferResponseWriter) Header() http.Header {
+	return w.header
+}
+
+func (w *bufferResponseWriter) WriteHeader(statusCode int) {}
@@ -0,0 +1,112 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sgo-api/base"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	timeoutParentContextKey = "sgo-api.timeout_parent"
+)
+
+type TimeoutConfig struct {
+	Default time.Duration // 默认超时时间，不作用于 WebSocket、SSE、NDJSON 长连接。默认为 0，即只使用 Routes 中的配置
+
+	// 按路由设置超时时间，优先级高于 Default。key 为 "GET /users/:id" 或 "/users/:id"（匹配所有方法）
+	Routes map[string]time.Duration
+
+	// 读取上游传入的剩余时间（毫秒），与本地超时时间取较小者，比如 base.ReqDeadlineHeader。默认不读取
+	PropagationHeader string
+}
+
+// 超时中间件，给请求的 context 设置截止时间
+//   - 处理器需要把 context 传给 GORM（db.WithContext）、req（SetContext）等，才能在超时后及时结束
+//   - 处理器仍在当前协程中执行，超时后不会并发写响应；处理器结束后，如果超时且未写响应，则响应 504
+//   - WebSocket、SSE、NDJSON 等长连接不受截止时间限制，只在客户端断开时结束
+func TimeoutMiddleware(conf TimeoutConfig) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		d, ok := conf.Routes[c.Request.Method+" "+c.FullPath()]
+		if !ok {
+			if d, ok = conf.Routes[c.FullPath()]; !ok {
+				d = conf.Default
+			}
+		}
+
+		if conf.PropagationHeader != "" {
+			if ms, err := strconv.ParseInt(c.GetHeader(conf.PropagationHeader), 10, 64); err == nil && ms > 0 {
+				if upstream := time.Duration(ms) * time.Millisecond; d <= 0 || upstream < d {
+					d = upstream
+				}
+			}
+		}
+
+		if d <= 0 {
+			c.Next()
+			return
+		}
+
+		withTimeout(c, d)
+	}
+}
+
+// 给单个路由或路由组设置超时时间。context 的截止时间只能缩短，不能延长
+func Timeout(d time.Duration) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		withTimeout(c, d)
+	}
+}
+
+func withTimeout(c *gin.Context, d time.Duration) {
+	if _, ok := c.Get(timeoutParentContextKey); !ok {
+		c.Set(timeoutParentContextKey, c.Request.Context())
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), d)
+	defer cancel()
+
+	c.Request = c.Request.WithContext(ctx)
+
+	c.Next()
+
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) && !c.Writer.Written() && len(c.Errors) == 0 {
+		c.Error(base.NewHttpErrorf(http.StatusGatewayTimeout, "请求超时"))
+	}
+}
+
+// 去掉超时中间件设置的截止时间，用于 WebSocket 等长连接。保留 context 中的值，客户端断开时仍然会结束
+func withoutTimeout(c *gin.Context) context.Context {
+	ctx := c.Request.Context()
+	v, ok := c.Get(timeoutParentContextKey)
+	if !ok {
+		return ctx
+	}
+	parent, ok := v.(context.Context)
+	if !ok {
+		return ctx
+	}
+	return detachedContext{Context: ctx, parent: parent}
+}
+
+// 值来自 Context，结束信号和截止时间来自 parent
+type detachedContext struct {
+	context.Context
+	parent context.Context
+}
+
+func (c detachedContext) Deadline() (time.Time, bool) {
+	return c.parent.Deadline()
+}
+
+func (c detachedContext) Done() <-chan struct{} {
+	return c.parent.Done()
+}
+
+func (c detachedContext) Err() error {
+	return c.parent.Err()
+}
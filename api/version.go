@@ -0,0 +1,306 @@
+package api
+
+import (
+	"context"
+	"mime"
+	"net/http"
+	"sgo-api/base"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/samber/oops"
+)
+
+const (
+	unsupportedVersionContextKey = "sgo-api.unsupported_version"
+)
+
+const (
+	VersionByPath      = "path"       // 路径前缀：/v2/users
+	VersionByHeader    = "header"     // 请求头：Api-Version: 2
+	VersionByMediaType = "media_type" // Accept：application/vnd.<Vendor>.v2+json 或 application/json; version=2
+)
+
+type VersionConfig struct {
+	Strategy string // 版本协商方式，见 VersionByPath 等。默认按路径前缀
+	Header   string // 按请求头协商时使用的请求头，响应也会带上该头。默认为 Api-Version
+	Vendor   string // 按 Accept 协商时 application/vnd.<Vendor>.v2+json 中的 Vendor，为空时只识别 version 参数
+	Default  string // 请求没有指定版本时使用的版本。默认为第一个注册的版本
+}
+
+// 弃用信息
+type Deprecation struct {
+	Date   time.Time // 弃用时间，为零值时 Deprecation 响应头为 true
+	Sunset time.Time // 下线时间，为零值时不设置 Sunset 响应头
+	Link   string    // 迁移说明的链接
+}
+
+// 多版本路由，每个版本是一个路径前缀为版本名（比如 v1）的路由组
+//   - 按路径协商时直接访问 /v1/users
+//   - 按请求头或 Accept 协商时访问 /users，在进入 gin 之前改写为对应版本的路径，日志中记录的是改写后的路径
+type Versions struct {
+	conf VersionConfig
+
+	versions []string
+	rejected bool // 是否使用了 Middleware
+}
+
+func NewVersions(conf VersionConfig) *Versions {
+	if conf.Strategy == "" {
+		conf.Strategy = VersionByPath
+	}
+	if conf.Header == "" {
+		conf.Header = "Api-Version"
+	}
+
+	return &Versions{conf: conf}
+}
+
+// 创建版本的路由组，deprecation 不为 nil 时该版本的所有接口都带上弃用响应头
+func (v *Versions) Group(r gin.IRouter, version string, deprecation *Deprecation) *gin.RouterGroup {
+	return r.Group(version, v.middleware(version, deprecation))
+}
+
+// 创建版本的路由组并生成文档，deprecation 不为 nil 时文档中的接口标记为弃用
+func (v *Versions) Router(r *Router, version string, deprecation *Deprecation) *Router {
+	g := r.Group(version, v.middleware(version, deprecation))
+	if deprecation != nil {
+		g.opts = append(g.opts, WithDeprecated())
+	}
+	return g
+}
+
+func (v *Versions) middleware(version string, deprecation *Deprecation) gin.HandlerFunc {
+	v.versions = append(v.versions, version)
+
+	return func(c *gin.Context) {
+		c.Header(v.conf.Header, version)
+		if deprecation != nil {
+			setDeprecation(c, deprecation)
+		}
+		c.Next()
+	}
+}
+
+// 弃用单个路由或路由组，响应带上 Deprecation、Sunset、Link 响应头，并记录警告日志
+func Deprecated(deprecation Deprecation) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		setDeprecation(c, &deprecation)
+		c.Next()
+	}
+}
+
+func setDeprecation(c *gin.Context, d *Deprecation) {
+	if d.Date.IsZero() {
+		c.Header("Deprecation", "true")
+	} else {
+		c.Header("Deprecation", "@"+strconv.FormatInt(d.Date.Unix(), 10))
+	}
+	if !d.Sunset.IsZero() {
+		c.Header("Sunset", d.Sunset.UTC().Format(http.TimeFormat))
+	}
+	if d.Link != "" {
+		c.Writer.Header().Add("Link", "<"+d.Link+`>; rel="deprecation"`)
+	}
+
+	msg := "调用已弃用的接口：%v %v"
+	args := []any{c.Request.Method, c.FullPath()}
+	if !d.Sunset.IsZero() {
+		msg += "，将于 %v 下线"
+		args = append(args, d.Sunset.Format(time.DateOnly))
+	}
+	getLogger(c, base.DefaultLogger()).Warnf(msg, args...)
+}
+
+// 响应不支持的版本，需要全局使用，放在 ErrorMiddleware 之后。Init 设置了 Versions 时会自动使用
+func (v *Versions) Middleware() gin.HandlerFunc {
+	v.rejected = true
+
+	return func(c *gin.Context) {
+		if version, ok := c.Request.Context().Value(unsupportedVersionContextKey).(string); ok {
+			c.Error(base.NewBadRequestErrorf("不支持的版本 %v", version))
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+// 按请求头或 Accept 协商版本，把 /users 改写为 /v2/users。需要在注册完路由后调用，按路径协商时直接返回 engine
+// 不支持的版本不改写路径，交给 Middleware 响应 400，错误响应同样经过日志、跨域等中间件
+func (v *Versions) Handler(engine *gin.Engine) http.Handler {
+	if v.conf.Strategy == VersionByPath || len(v.versions) == 0 {
+		return engine
+	}
+	if !v.rejected {
+		panic(oops.Errorf("按请求头或 Accept 协商版本时需要全局使用 Versions.Middleware"))
+	}
+
+	known := map[string]bool{}
+	for _, version := range v.versions {
+		known[version] = true
+	}
+
+	// 去掉版本段后的路由模板，以及版本段所在的位置
+	type template struct {
+		segments []string
+		index    int
+	}
+	templates := []template{}
+	seen := map[string]bool{}
+	for _, route := range engine.Routes() {
+		segments := strings.Split(strings.Trim(route.Path, "/"), "/")
+		for i, s := range segments {
+			if !known[s] {
+				continue
+			}
+			unversioned := append(append([]string{}, segments[:i]...), segments[i+1:]...)
+			key := strings.Join(unversioned, "/") + "#" + strconv.Itoa(i)
+			if !seen[key] {
+				seen[key] = true
+				templates = append(templates, template{segments: unversioned, index: i})
+			}
+			break
+		}
+	}
+
+	defaultVersion := v.conf.Default
+	if defaultVersion == "" {
+		defaultVersion = v.versions[0]
+	}
+	vary := v.conf.Header
+	if v.conf.Strategy == VersionByMediaType {
+		vary = "Accept"
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		segments := strings.Split(strings.Trim(req.URL.Path, "/"), "/")
+		for _, s := range segments {
+			if known[s] {
+				engine.ServeHTTP(w, req)
+				return
+			}
+		}
+
+		index := -1
+		for _, t := range templates {
+			if matchSegments(t.segments, segments) {
+				index = t.index
+				break
+			}
+		}
+		if index < 0 {
+			engine.ServeHTTP(w, req)
+			return
+		}
+
+		w.Header().Add("Vary", vary)
+
+		version := v.negotiate(req)
+		if version == "" {
+			version = defaultVersion
+		} else if !known[version] {
+			engine.ServeHTTP(w, req.WithContext(context.WithValue(req.Context(), unsupportedVersionContextKey, version)))
+			return
+		}
+
+		segments = append(segments[:index], append([]string{version}, segments[index:]...)...)
+		req.URL.Path = "/" + strings.Join(segments, "/")
+		req.URL.RawPath = ""
+		engine.ServeHTTP(w, req)
+	})
+}
+
+// 从请求中读取版本，没有指定时返回空字符串。2 和 v2 都识别为 v2
+func (v *Versions) negotiate(req *http.Request) string {
+	value := ""
+	switch v.conf.Strategy {
+	case VersionByHeader:
+		value = strings.TrimSpace(req.Header.Get(v.conf.Header))
+	case VersionByMediaType:
+		value = v.mediaTypeVersion(req.Header.Get("Accept"))
+	}
+	if value == "" {
+		return ""
+	}
+
+	for _, version := range v.versions {
+		if value == version || "v"+value == version {
+			return version
+		}
+	}
+	return value
+}
+
+func (v *Versions) mediaTypeVersion(accept string) string {
+	for _, part := range strings.Split(accept, ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+
+		if version := params["version"]; version != "" {
+			return version
+		}
+		if v.conf.Vendor != "" {
+			prefix := "application/vnd." + v.conf.Vendor + "."
+			if rest, ok := strings.CutPrefix(mediaType, prefix); ok {
+				version, _, _ := strings.Cut(rest, "+")
+				return version
+			}
+		}
+	}
+	return ""
+}
+
+// 按 gin 的路由模板匹配路径：:name 匹配一段，*name 匹配剩余部分
+func matchSegments(template []string, segments []string) bool {
+	for i, t := range template {
+		if strings.HasPrefix(t, "*") {
+			return true
+		}
+		if i >= len(segments) {
+			return false
+		}
+		if !strings.HasPrefix(t, ":") && t != segments[i] {
+			return false
+		}
+	}
+	return len(template) == len(segments)
+}
+
+// 把处理器适配为另一个版本的输入输出，输入输出通过 mapper 映射，mapper 为 nil 时使用 base.Map
+//
+//	api.Route(v2, http.MethodPost, "/users", createUser)
+//	api.Route(v1, http.MethodPost, "/users", api.Adapt[CreateUserV1, UserV1](createUser, nil))
+func Adapt[VI any, VO any, I any, O any](handler func(ctx context.Context, im I) (O, error), mapper base.Mapper) func(ctx context.Context, im VI) (VO, error) {
+	output := MapTo[O, VO](mapper)
+	return func(ctx context.Context, vim VI) (VO, error) {
+		var im I
+		if _, ok := any(vim).(Empty); !ok {
+			var err error
+			if mapper != nil {
+				err = mapper.Map(vim, &im)
+			} else {
+				err = base.Map(vim, &im)
+			}
+			if err != nil {
+				var zeroValue VO
+				return zeroValue, base.NewBadRequestError(oops.Wrapf(err, "映射输入失败"))
+			}
+		}
+
+		om, err := handler(ctx, im)
+		if err != nil {
+			var zeroValue VO
+			return zeroValue, oops.Wrap(err)
+		}
+		if _, ok := any(om).(Empty); ok {
+			var zeroValue VO
+			return zeroValue, nil
+		}
+		return output(ctx, om)
+	}
+}
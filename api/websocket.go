@@ -0,0 +1,401 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sgo-api/base"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+	"github.com/samber/oops"
+)
+
+const (
+	WSTextMessage   = websocket.TextMessage
+	WSBinaryMessage = websocket.BinaryMessage
+)
+
+var (
+	ErrWSSendQueueFull = errors.New("发送队列已满")
+	ErrWSClosed        = errors.New("连接已关闭")
+)
+
+type WebSocketConfig struct {
+	TraceContextKey string // 未经过 LogMiddleware 时，生成 Trace ID 放入 context 使用的 key。默认为 base.TraceContextKey
+
+	ReadLimit     int64         // 单条消息的大小上限（字节）。默认为 64KB
+	PingInterval  time.Duration // 发送 ping 的间隔。默认为 30s
+	PongTimeout   time.Duration // 超过该时间没有收到消息或 pong 时断开。默认为 PingInterval 的 2 倍
+	WriteTimeout  time.Duration // 单条消息的写超时。默认为 10s
+	SendQueueSize int           // 每个连接的发送队列长度。默认为 256
+
+	CheckOrigin       func(r *http.Request) bool // 校验来源。默认只允许同源
+	Subprotocols      []string
+	EnableCompression bool
+}
+
+type WebSocketHandler struct {
+	OnConnect func(conn *WSConn) error                // 连接建立后调用，返回错误时断开
+	OnMessage func(conn *WSConn, msg WSMessage) error // 收到消息时调用，在读协程中串行执行，返回错误时断开
+	OnClose   func(conn *WSConn)                      // 断开后调用
+}
+
+type WSMessage struct {
+	Type int // WSTextMessage 或 WSBinaryMessage
+	Data []byte
+}
+
+// WebSocket 连接，发送通过队列在独立的协程中进行，可以并发调用
+type WSConn struct {
+	ID string // 与 Trace ID 相同
+
+	conn *websocket.Conn
+	conf WebSocketConfig
+	log  base.Logger
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	send   chan WSMessage
+	done   chan struct{}
+
+	lock   sync.Mutex
+	groups map[*Hub]map[string]bool
+
+	received      atomic.Int64
+	receivedBytes atomic.Int64
+	sent          atomic.Int64
+	sentBytes     atomic.Int64
+}
+
+// 连接的 context，断开时结束，带有 Trace ID
+func (c *WSConn) Context() context.Context {
+	return c.ctx
+}
+
+func (c *WSConn) Log() base.Logger {
+	return c.log
+}
+
+// 发送消息，队列满时阻塞直到有空位、ctx 结束或连接断开
+func (c *WSConn) Send(ctx context.Context, msg WSMessage) error {
+	select {
+	case c.send <- msg:
+		return nil
+	case <-ctx.Done():
+		return oops.Wrap(ctx.Err())
+	case <-c.ctx.Done():
+		return oops.Wrap(ErrWSClosed)
+	}
+}
+
+// 发送消息，队列满时直接返回 ErrWSSendQueueFull
+func (c *WSConn) TrySend(msg WSMessage) error {
+	if c.ctx.Err() != nil {
+		return oops.Wrap(ErrWSClosed)
+	}
+
+	select {
+	case c.send <- msg:
+		return nil
+	default:
+		return oops.Wrap(ErrWSSendQueueFull)
+	}
+}
+
+// 发送 JSON 文本消息，队列满时阻塞
+func (c *WSConn) SendJSON(ctx context.Context, v any) error {
+	data, err := marshalJSON(v)
+	if err != nil {
+		return oops.Wrap(err)
+	}
+	return c.Send(ctx, WSMessage{Type: WSTextMessage, Data: data})
+}
+
+// 断开连接
+func (c *WSConn) Close() {
+	c.cancel()
+}
+
+// 升级为 WebSocket 并处理连接，连接断开后才返回
+//   - Trace ID 与 LogMiddleware 相同，未经过 LogMiddleware 时生成一个
+//   - 日志记录连接、断开和每条消息的类型和大小，不记录消息内容
+//   - 定时发送 ping，超时没有收到消息或 pong 时断开
+//   - 连接的 context 去掉了 TimeoutMiddleware 设置的截止时间，保留其中的值（比如认证主体）
+func WebSocket(log base.Logger, conf WebSocketConfig, handler WebSocketHandler) gin.HandlerFunc {
+	log = log.WithTag("WS")
+
+	if conf.TraceContextKey == "" {
+		conf.TraceContextKey = base.TraceContextKey
+	}
+	if conf.ReadLimit <= 0 {
+		conf.ReadLimit = 64 << 10
+	}
+	if conf.PingInterval <= 0 {
+		conf.PingInterval = 30 * time.Second
+	}
+	if conf.PongTimeout <= 0 {
+		conf.PongTimeout = 2 * conf.PingInterval
+	}
+	if conf.WriteTimeout <= 0 {
+		conf.WriteTimeout = 10 * time.Second
+	}
+	if conf.SendQueueSize <= 0 {
+		conf.SendQueueSize = 256
+	}
+
+	upgrader := websocket.Upgrader{
+		CheckOrigin:       conf.CheckOrigin,
+		Subprotocols:      conf.Subprotocols,
+		EnableCompression: conf.EnableCompression,
+	}
+
+	return func(c *gin.Context) {
+		traceID := GetTraceID(c)
+		l := getLogger(c, nil)
+		if traceID == "" || l == nil {
+			if traceID == "" {
+				traceID = base.NewNanoID()
+			}
+			l = setTrace(c, log, conf.TraceContextKey, traceID)
+		} else {
+			l = l.WithTag("WS")
+		}
+
+		SkipResponseCapture(c)
+		ws, err := upgrader.Upgrade(c.Writer, c.Request, nil)
+		if err != nil {
+			// Upgrader 已经响应了错误
+			l.Warnf("升级 WebSocket 失败：%v", err)
+			return
+		}
+
+		// 连接的生命周期不受 TimeoutMiddleware 的截止时间限制
+		ctx, cancel := context.WithCancel(withoutTimeout(c))
+		conn := &WSConn{
+			ID:     traceID,
+			conn:   ws,
+			conf:   conf,
+			log:    l,
+			ctx:    ctx,
+			cancel: cancel,
+			send:   make(chan WSMessage, conf.SendQueueSize),
+			done:   make(chan struct{}),
+			groups: map[*Hub]map[string]bool{},
+		}
+		conn.serve(c, handler)
+	}
+}
+
+func (c *WSConn) serve(gc *gin.Context, handler WebSocketHandler) {
+	start := time.Now()
+	c.log.Infof("WS 连接：%v %v", gc.ClientIP(), gc.Request.URL.Path)
+
+	go c.writeLoop()
+
+	defer func() {
+		c.cancel()
+		<-c.done
+		c.conn.Close()
+		c.leaveAll()
+
+		if handler.OnClose != nil {
+			base.Try(func() {
+				handler.OnClose(c)
+			}).Catch(func(err error) {
+				c.log.Errorf("WS OnClose 恐慌：%+v", err)
+			}).Do()
+		}
+
+		setResponseSummary(gc, "[WebSocket]")
+		c.log.Infof("WS 断开：收到 %d 条 %d bytes，发送 %d 条 %d bytes，时长 %v",
+			c.received.Load(), c.receivedBytes.Load(),
+			c.sent.Load(), c.sentBytes.Load(),
+			time.Since(start),
+		)
+	}()
+
+	if handler.OnConnect != nil {
+		if err := c.call(func() error { return handler.OnConnect(c) }); err != nil {
+			c.log.Warnf("WS OnConnect 失败：%+v", err)
+			return
+		}
+	}
+
+	c.conn.SetReadLimit(c.conf.ReadLimit)
+	c.conn.SetReadDeadline(time.Now().Add(c.conf.PongTimeout))
+	c.conn.SetPongHandler(func(string) error {
+		return c.conn.SetReadDeadline(time.Now().Add(c.conf.PongTimeout))
+	})
+
+	// ctx 结束时（比如调用 Close）中断读取
+	go func() {
+		<-c.ctx.Done()
+		c.conn.SetReadDeadline(time.Now())
+	}()
+
+	for {
+		typ, data, err := c.conn.ReadMessage()
+		if err != nil {
+			if c.ctx.Err() == nil && !websocket.IsCloseError(err, websocket.CloseNormalClosure, websocket.CloseGoingAway, websocket.CloseNoStatusReceived) {
+				c.log.Warnf("WS 读取失败：%v", err)
+			}
+			return
+		}
+		c.conn.SetReadDeadline(time.Now().Add(c.conf.PongTimeout))
+
+		c.received.Add(1)
+		c.receivedBytes.Add(int64(len(data)))
+		c.log.Debugf("WS 收到：%v %d bytes", wsMessageType(typ), len(data))
+
+		if handler.OnMessage != nil {
+			if err := c.call(func() error { return handler.OnMessage(c, WSMessage{Type: typ, Data: data}) }); err != nil {
+				c.log.Warnf("WS 处理消息失败：%+v", err)
+				return
+			}
+		}
+	}
+}
+
+func (c *WSConn) call(f func() error) (err error) {
+	base.Try(func() {
+		err = f()
+	}).Catch(func(e error) {
+		err = oops.Wrapf(e, "恐慌")
+	}).Do()
+	return
+}
+
+func (c *WSConn) writeLoop() {
+	defer close(c.done)
+
+	ticker := time.NewTicker(c.conf.PingInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-c.ctx.Done():
+			c.conn.WriteControl(websocket.CloseMessage,
+				websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""),
+				time.Now().Add(c.conf.WriteTimeout))
+			return
+		case msg := <-c.send:
+			c.conn.SetWriteDeadline(time.Now().Add(c.conf.WriteTimeout))
+			if err := c.conn.WriteMessage(msg.Type, msg.Data); err != nil {
+				c.log.Warnf("WS 发送失败：%v", err)
+				c.cancel()
+				return
+			}
+			c.sent.Add(1)
+			c.sentBytes.Add(int64(len(msg.Data)))
+			c.log.Debugf("WS 发送：%v %d bytes", wsMessageType(msg.Type), len(msg.Data))
+		case <-ticker.C:
+			if err := c.conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(c.conf.WriteTimeout)); err != nil {
+				c.cancel()
+				return
+			}
+		}
+	}
+}
+
+func wsMessageType(typ int) string {
+	if typ == WSBinaryMessage {
+		return "binary"
+	}
+	return "text"
+}
+
+func (c *WSConn) leaveAll() {
+	c.lock.Lock()
+	groups := c.groups
+	c.groups = map[*Hub]map[string]bool{}
+	c.lock.Unlock()
+
+	for h, gs := range groups {
+		for g := range gs {
+			h.Leave(g, c)
+		}
+	}
+}
+
+// 按分组广播消息，连接断开时自动离开所有分组
+type Hub struct {
+	lock   sync.RWMutex
+	groups map[string]map[*WSConn]bool
+}
+
+func NewHub() *Hub {
+	return &Hub{groups: map[string]map[*WSConn]bool{}}
+}
+
+func (h *Hub) Join(group string, conn *WSConn) {
+	h.lock.Lock()
+	if h.groups[group] == nil {
+		h.groups[group] = map[*WSConn]bool{}
+	}
+	h.groups[group][conn] = true
+	h.lock.Unlock()
+
+	conn.lock.Lock()
+	if conn.groups[h] == nil {
+		conn.groups[h] = map[string]bool{}
+	}
+	conn.groups[h][group] = true
+	conn.lock.Unlock()
+}
+
+func (h *Hub) Leave(group string, conn *WSConn) {
+	h.lock.Lock()
+	if conns, ok := h.groups[group]; ok {
+		delete(conns, conn)
+		if len(conns) == 0 {
+			delete(h.groups, group)
+		}
+	}
+	h.lock.Unlock()
+
+	conn.lock.Lock()
+	delete(conn.groups[h], group)
+	conn.lock.Unlock()
+}
+
+func (h *Hub) Count(group string) int {
+	h.lock.RLock()
+	defer h.lock.RUnlock()
+
+	return len(h.groups[group])
+}
+
+// 向分组内的所有连接广播，不会阻塞：发送队列已满的连接跟不上消息，直接断开，返回成功入队的连接数
+func (h *Hub) Broadcast(group string, msg WSMessage) int {
+	h.lock.RLock()
+	conns := make([]*WSConn, 0, len(h.groups[group]))
+	for conn := range h.groups[group] {
+		conns = append(conns, conn)
+	}
+	h.lock.RUnlock()
+
+	n := 0
+	for _, conn := range conns {
+		if err := conn.TrySend(msg); err != nil {
+			if errors.Is(err, ErrWSSendQueueFull) {
+				conn.log.Warnf("WS 发送队列已满，断开连接")
+				conn.Close()
+			}
+			continue
+		}
+		n++
+	}
+	return n
+}
+
+func (h *Hub) BroadcastJSON(group string, v any) (int, error) {
+	data, err := marshalJSON(v)
+	if err != nil {
+		return 0, oops.Wrap(err)
+	}
+	return h.Broadcast(group, WSMessage{Type: WSTextMessage, Data: data}), nil
+}
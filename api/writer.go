@@ -9,22 +9,58 @@ import (
 type Writer struct {
 	gin.ResponseWriter
 	body *bytes.Buffer
+
+	skip    bool
+	summary string
 }
 
 func NewWriter(w gin.ResponseWriter) *Writer {
 	return &Writer{body: bytes.NewBufferString(""), ResponseWriter: w}
 }
 
-func (w Writer) Write(b []byte) (int, error) {
-	w.body.Write(b)
+func (w *Writer) Write(b []byte) (int, error) {
+	if !w.skip {
+		w.body.Write(b)
+	}
 	return w.ResponseWriter.Write(b)
 }
 
-func (w Writer) WriteString(s string) (int, error) {
-	w.body.WriteString(s)
+func (w *Writer) WriteString(s string) (int, error) {
+	if !w.skip {
+		w.body.WriteString(s)
+	}
 	return w.ResponseWriter.WriteString(s)
 }
 
-func (w Writer) String() string {
+func (w *Writer) String() string {
 	return w.body.String()
 }
+
+// 不再记录响应内容，用于文件下载、流式响应等
+func (w *Writer) Skip() {
+	w.skip = true
+	w.body.Reset()
+}
+
+func (w *Writer) Skipped() bool {
+	return w.skip
+}
+
+// 不记录响应内容时，日志中记录的摘要
+func (w *Writer) Summary() string {
+	return w.summary
+}
+
+// 当前请求不记录响应内容，未经过 LogMiddleware 时不处理
+func SkipResponseCapture(c *gin.Context) {
+	if w, ok := c.Writer.(*Writer); ok {
+		w.Skip()
+	}
+}
+
+// 设置日志中记录的响应摘要，比如流式响应的事件数量
+func setResponseSummary(c *gin.Context, summary string) {
+	if w, ok := c.Writer.(*Writer); ok {
+		w.summary = summary
+	}
+}
@@ -0,0 +1,278 @@
+package base
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/samber/oops"
+)
+
+// 审计记录，通过哈希链防篡改：Hash 为 PrevHash 和记录内容的 SHA-256，Seq 连续递增，删除或修改记录都能被 VerifyAuditChain 发现
+type AuditRecord struct {
+	Chain    string    `json:"chain" gorm:"size:64;uniqueIndex:idx_audit_chain_seq"`
+	Seq      int64     `json:"seq" gorm:"uniqueIndex:idx_audit_chain_seq"`
+	Time     time.Time `json:"time"`
+	TraceID  string    `json:"trace_id" gorm:"size:128"`
+	PrevHash string    `json:"prev_hash" gorm:"size:64"`
+	Hash     string    `json:"hash" gorm:"size:64"`
+
+	PrincipalID   string `json:"principal_id" gorm:"size:128;index"`
+	PrincipalName string `json:"principal_name" gorm:"size:128"`
+	PrincipalType string `json:"principal_type" gorm:"size:32"`
+
+	IP       string            `json:"ip" gorm:"size:64"`
+	Method   string            `json:"method" gorm:"size:16"`
+	Route    string            `json:"route" gorm:"size:255;index"`
+	Path     string            `json:"path" gorm:"size:2048"`
+	Params   map[string]string `json:"params" gorm:"serializer:json"`
+	Body     string            `json:"body"`
+	Status   int               `json:"status"`
+	Response string            `json:"response"`
+}
+
+// 计算记录的哈希，不包含 Hash 本身
+func (r AuditRecord) ComputeHash() (string, error) {
+	r.Hash = ""
+	r.Time = r.Time.UTC()
+	if len(r.Params) == 0 {
+		r.Params = nil
+	}
+
+	data, err := json.Marshal(r)
+	if err != nil {
+		return "", oops.Wrap(err)
+	}
+
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// 审计记录的存储
+type AuditSink interface {
+	Write(ctx context.Context, record AuditRecord) error
+
+	// 获取链上最后一条记录，没有记录时返回 nil
+	Last(ctx context.Context, chain string) (*AuditRecord, error)
+}
+
+type AuditLoggerConfig struct {
+	Chain string // 链名称，多个实例写入同一个存储时需要各自使用不同的链。默认为 default
+	Sink  AuditSink
+}
+
+// 审计日志器，给记录分配序号并串联哈希后写入存储
+type AuditLogger struct {
+	chain string
+	sink  AuditSink
+
+	lock     chan struct{} // 可以随 ctx 结束放弃等待的锁
+	seq      int64
+	lastHash string
+}
+
+func NewAuditLogger(ctx context.Context, conf AuditLoggerConfig) (*AuditLogger, error) {
+	if conf.Sink == nil {
+		return nil, oops.Errorf("审计存储不能为空")
+	}
+	if conf.Chain == "" {
+		conf.Chain = "default"
+	}
+
+	l := &AuditLogger{chain: conf.Chain, sink: conf.Sink, lock: make(chan struct{}, 1)}
+
+	last, err := conf.Sink.Last(ctx, conf.Chain)
+	if err != nil {
+		return nil, oops.Wrapf(err, "读取最后一条审计记录失败")
+	}
+	if last != nil {
+		l.seq = last.Seq
+		l.lastHash = last.Hash
+	}
+
+	return l, nil
+}
+
+// 写入记录，会覆盖 Chain、Seq、PrevHash、Hash，Time 为空时使用当前时间
+// 记录串行写入，ctx 结束时放弃等待和写入，调用方应设置超时
+func (l *AuditLogger) Write(ctx context.Context, record AuditRecord) (AuditRecord, error) {
+	select {
+	case l.lock <- struct{}{}:
+	case <-ctx.Done():
+		return record, oops.Wrapf(ctx.Err(), "等待写入审计记录超时")
+	}
+	defer func() { <-l.lock }()
+
+	if record.Time.IsZero() {
+		record.Time = time.Now()
+	}
+	// 数据库一般只保存到毫秒，截断后读出来的记录才能校验通过
+	record.Time = record.Time.UTC().Truncate(time.Millisecond)
+	record.Chain = l.chain
+	record.Seq = l.seq + 1
+	record.PrevHash = l.lastHash
+
+	hash, err := record.ComputeHash()
+	if err != nil {
+		return record, oops.Wrap(err)
+	}
+	record.Hash = hash
+
+	if err := l.sink.Write(ctx, record); err != nil {
+		return record, oops.Wrapf(err, "写入审计记录失败")
+	}
+
+	l.seq = record.Seq
+	l.lastHash = record.Hash
+	return record, nil
+}
+
+// 校验按 Seq 升序排列的一段记录，第一条记录的 PrevHash 不做校验
+func VerifyAuditChain(records []AuditRecord) error {
+	for i, r := range records {
+		hash, err := r.ComputeHash()
+		if err != nil {
+			return oops.Wrap(err)
+		}
+		if hash != r.Hash {
+			return oops.Errorf("审计记录 %v#%d 的哈希不匹配，记录被修改", r.Chain, r.Seq)
+		}
+
+		if i == 0 {
+			continue
+		}
+
+		prev := records[i-1]
+		if r.Seq != prev.Seq+1 {
+			return oops.Errorf("审计记录 %v#%d 之后缺少记录，下一条为 #%d", r.Chain, prev.Seq, r.Seq)
+		}
+		if r.PrevHash != prev.Hash {
+			return oops.Errorf("审计记录 %v#%d 的前一条哈希不匹配，记录被删除或修改", r.Chain, r.Seq)
+		}
+	}
+	return nil
+}
+
+// 按 JSON Lines 格式写入文件
+type FileAuditSink struct {
+	path string
+	lock sync.Mutex
+}
+
+func NewFileAuditSink(path string) (*FileAuditSink, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, oops.Wrapf(err, "创建审计目录失败")
+	}
+	return &FileAuditSink{path: path}, nil
+}
+
+func (s *FileAuditSink) Write(ctx context.Context, record AuditRecord) error {
+	data, err := json.Marshal(record)
+	if err != nil {
+		return oops.Wrap(err)
+	}
+	data = append(data, '\n')
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	f, err := os.OpenFile(s.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		return oops.Wrap(err)
+	}
+	defer f.Close()
+
+	if _, err := f.Write(data); err != nil {
+		return oops.Wrap(err)
+	}
+	return oops.Wrap(f.Sync())
+}
+
+func (s *FileAuditSink) Last(ctx context.Context, chain string) (*AuditRecord, error) {
+	records, err := s.Read(chain)
+	if err != nil {
+		return nil, oops.Wrap(err)
+	}
+	if len(records) == 0 {
+		return nil, nil
+	}
+	return &records[len(records)-1], nil
+}
+
+// 读取链上的所有记录，用于 VerifyAuditChain
+func (s *FileAuditSink) Read(chain string) ([]AuditRecord, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	f, err := os.Open(s.path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, oops.Wrap(err)
+	}
+	defer f.Close()
+
+	records := []AuditRecord{}
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64<<10), 16<<20)
+	for scanner.Scan() {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+
+		var r AuditRecord
+		if err := json.Unmarshal(scanner.Bytes(), &r); err != nil {
+			return nil, oops.Wrapf(err, "解析审计记录失败")
+		}
+		if r.Chain == chain {
+			records = append(records, r)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, oops.Wrap(err)
+	}
+
+	return records, nil
+}
+
+// 把记录发送到 channel，由调用方消费，比如转发到消息队列。channel 满时阻塞直到 ctx 结束，调用方需要设置超时
+type ChanAuditSink struct {
+	c chan<- AuditRecord
+
+	lock sync.Mutex
+	last map[string]AuditRecord
+}
+
+func NewChanAuditSink(c chan<- AuditRecord) *ChanAuditSink {
+	return &ChanAuditSink{c: c, last: map[string]AuditRecord{}}
+}
+
+func (s *ChanAuditSink) Write(ctx context.Context, record AuditRecord) error {
+	select {
+	case s.c <- record:
+	case <-ctx.Done():
+		return oops.Wrap(ctx.Err())
+	}
+
+	s.lock.Lock()
+	s.last[record.Chain] = record
+	s.lock.Unlock()
+	return nil
+}
+
+// 只记录本进程内发送过的最后一条
+func (s *ChanAuditSink) Last(ctx context.Context, chain string) (*AuditRecord, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if r, ok := s.last[chain]; ok {
+		return &r, nil
+	}
+	return nil, nil
+}
@@ -0,0 +1,322 @@
+package base
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/samber/oops"
+)
+
+var ErrBreakerOpen = errors.New("熔断器已打开")
+
+type BreakerState int32
+
+const (
+	BreakerClosed   BreakerState = iota // 关闭，正常放行
+	BreakerOpen                         // 打开，直接拒绝
+	BreakerHalfOpen                     // 半开，放行少量探测请求
+)
+
+func (s BreakerState) String() string {
+	switch s {
+	case BreakerClosed:
+		return "closed"
+	case BreakerOpen:
+		return "open"
+	case BreakerHalfOpen:
+		return "half-open"
+	default:
+		return fmt.Sprintf("unknown(%d)", s)
+	}
+}
+
+type BreakerConfig struct {
+	Name string
+	Log  Logger // 记录状态变化，为 nil 时不记录
+
+	Window              time.Duration // 关闭状态下的统计窗口，每个窗口结束时清零计数。默认为 60s
+	MinRequests         int           // 窗口内请求数达到此值后，才按失败率判断。默认为 10
+	FailureRatio        float64       // 窗口内失败率达到此值时打开。默认为 0.5
+	ConsecutiveFailures int           // 连续失败次数达到此值时打开。默认为 5
+	OpenTimeout         time.Duration // 打开后经过多久进入半开。默认为 30s
+	HalfOpenRequests    int           // 半开状态允许的探测请求数，全部成功后关闭，任一失败则重新打开。默认为 1
+
+	// 判断错误是否计为失败。默认为 IsBreakerFailure
+	IsFailure func(err error) bool
+
+	OnStateChange func(name string, from BreakerState, to BreakerState)
+}
+
+func (c BreakerConfig) withDefault() BreakerConfig {
+	if c.Window <= 0 {
+		c.Window = 60 * time.Second
+	}
+	if c.MinRequests <= 0 {
+		c.MinRequests = 10
+	}
+	if c.FailureRatio <= 0 {
+		c.FailureRatio = 0.5
+	}
+	if c.ConsecutiveFailures <= 0 {
+		c.ConsecutiveFailures = 5
+	}
+	if c.OpenTimeout <= 0 {
+		c.OpenTimeout = 30 * time.Second
+	}
+	if c.HalfOpenRequests <= 0 {
+		c.HalfOpenRequests = 1
+	}
+	if c.IsFailure == nil {
+		c.IsFailure = IsBreakerFailure
+	}
+	return c
+}
+
+// 默认的失败判断：HttpError 只有可重试的状态码（5xx、429 等）计为失败，其他错误都计为失败
+func IsBreakerFailure(err error) bool {
+	if err == nil {
+		return false
+	}
+
+	var he *HttpError
+	if errors.As(err, &he) {
+		return IsRetryableStatusCode(he.Code())
+	}
+
+	return true
+}
+
+// 熔断器
+type Breaker struct {
+	conf BreakerConfig
+
+	lock       sync.Mutex
+	state      BreakerState
+	generation uint64
+	expiry     time.Time // 关闭：窗口结束时间；打开：进入半开的时间
+
+	requests    int
+	failures    int
+	consecutive int
+
+	halfOpenInFlight int
+	halfOpenSuccess  int
+}
+
+func NewBreaker(conf BreakerConfig) *Breaker {
+	b := &Breaker{conf: conf.withDefault()}
+	b.expiry = time.Now().Add(b.conf.Window)
+	return b
+}
+
+func (b *Breaker) Name() string {
+	return b.conf.Name
+}
+
+func (b *Breaker) State() BreakerState {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	b.refresh(time.Now())
+	return b.state
+}
+
+// 申请执行，熔断器打开时返回 503 错误（可用 errors.Is(err, ErrBreakerOpen) 判断）
+// 申请成功后，执行结束必须调用 done 上报结果
+func (b *Breaker) Allow() (done func(err error), err error) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	now := time.Now()
+	b.refresh(now)
+
+	switch b.state {
+	case BreakerOpen:
+		return nil, b.openError()
+	case BreakerHalfOpen:
+		if b.halfOpenInFlight+b.halfOpenSuccess >= b.conf.HalfOpenRequests {
+			return nil, b.openError()
+		}
+		b.halfOpenInFlight++
+	}
+
+	generation := b.generation
+	return func(err error) {
+		b.done(generation, err)
+	}, nil
+}
+
+// 在熔断器保护下执行 fn
+func (b *Breaker) Do(fn func() error) error {
+	done, err := b.Allow()
+	if err != nil {
+		return err
+	}
+
+	var fnErr error
+	Try(func() {
+		fnErr = fn()
+	}).Catch(func(err error) {
+		fnErr = oops.Wrapf(err, "恐慌")
+	}).Finally(func() {
+		done(fnErr)
+	}).Do()
+
+	return fnErr
+}
+
+// 同 Breaker.Do，但返回 fn 的结果
+func BreakerDo[T any](b *Breaker, fn func() (T, error)) (T, error) {
+	var value T
+	err := b.Do(func() error {
+		var err error
+		value, err = fn()
+		return err
+	})
+	return value, err
+}
+
+func (b *Breaker) openError() error {
+	if b.conf.Name == "" {
+		return NewServiceUnavailableError(ErrBreakerOpen)
+	}
+	return NewServiceUnavailableError(fmt.Errorf("%w：%v", ErrBreakerOpen, b.conf.Name))
+}
+
+func (b *Breaker) done(generation uint64, err error) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	now := time.Now()
+	b.refresh(now)
+
+	// 状态已经变化，结果作废
+	if generation != b.generation {
+		return
+	}
+
+	failed := b.conf.IsFailure(err)
+
+	switch b.state {
+	case BreakerClosed:
+		b.requests++
+		if failed {
+			b.failures++
+			b.consecutive++
+		} else {
+			b.consecutive = 0
+		}
+
+		if b.consecutive >= b.conf.ConsecutiveFailures ||
+			(b.requests >= b.conf.MinRequests && float64(b.failures)/float64(b.requests) >= b.conf.FailureRatio) {
+			b.setState(BreakerOpen, now)
+		}
+	case BreakerHalfOpen:
+		b.halfOpenInFlight--
+		if failed {
+			b.setState(BreakerOpen, now)
+		} else {
+			b.halfOpenSuccess++
+			if b.halfOpenSuccess >= b.conf.HalfOpenRequests {
+				b.setState(BreakerClosed, now)
+			}
+		}
+	}
+}
+
+// 按时间推进状态，需持有锁
+func (b *Breaker) refresh(now time.Time) {
+	switch b.state {
+	case BreakerClosed:
+		if !now.Before(b.expiry) {
+			b.reset(now)
+		}
+	case BreakerOpen:
+		if !now.Before(b.expiry) {
+			b.setState(BreakerHalfOpen, now)
+		}
+	}
+}
+
+func (b *Breaker) reset(now time.Time) {
+	b.generation++
+	b.requests = 0
+	b.failures = 0
+	b.consecutive = 0
+	b.halfOpenInFlight = 0
+	b.halfOpenSuccess = 0
+
+	switch b.state {
+	case BreakerClosed:
+		b.expiry = now.Add(b.conf.Window)
+	case BreakerOpen:
+		b.expiry = now.Add(b.conf.OpenTimeout)
+	default:
+		b.expiry = time.Time{}
+	}
+}
+
+func (b *Breaker) setState(state BreakerState, now time.Time) {
+	if b.state == state {
+		return
+	}
+
+	from := b.state
+	b.state = state
+	b.reset(now)
+
+	if b.conf.Log != nil {
+		if state == BreakerOpen {
+			b.conf.Log.WithTag("Breaker").Warnf("熔断器 %v 状态变化：%v -> %v", b.conf.Name, from, state)
+		} else {
+			b.conf.Log.WithTag("Breaker").Infof("熔断器 %v 状态变化：%v -> %v", b.conf.Name, from, state)
+		}
+	}
+	if b.conf.OnStateChange != nil {
+		Try(func() {
+			b.conf.OnStateChange(b.conf.Name, from, state)
+		}).Do()
+	}
+}
+
+// 按 key 区分的一组熔断器，比如每个下游主机一个
+type BreakerGroup struct {
+	conf     BreakerConfig
+	breakers *SyncMap[string, *Breaker]
+}
+
+func NewBreakerGroup(conf BreakerConfig) *BreakerGroup {
+	return &BreakerGroup{
+		conf:     conf,
+		breakers: NewSyncMap[string, *Breaker](),
+	}
+}
+
+// 获取 key 对应的熔断器，不存在时创建
+func (g *BreakerGroup) Get(key string) *Breaker {
+	if b, ok := g.breakers.Load(key); ok {
+		return b
+	}
+
+	conf := g.conf
+	if conf.Name == "" {
+		conf.Name = key
+	} else {
+		conf.Name = conf.Name + ":" + key
+	}
+
+	b, _ := g.breakers.LoadOrStore(key, NewBreaker(conf))
+	return b
+}
+
+// 所有熔断器的当前状态
+func (g *BreakerGroup) States() map[string]BreakerState {
+	states := map[string]BreakerState{}
+	g.breakers.Range(func(key string, b *Breaker) bool {
+		states[key] = b.State()
+		return true
+	})
+	return states
+}
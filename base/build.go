@@ -0,0 +1,51 @@
+package base
+
+import (
+	"runtime"
+	"runtime/debug"
+)
+
+type BuildInfo struct {
+	ENV        string `json:"env"`
+	Version    string `json:"version"`
+	Commit     string `json:"commit"`
+	BuildTime  string `json:"build_time"`
+	CommitTime string `json:"commit_time"`
+	Modified   bool   `json:"modified"` // 构建时工作区有未提交的修改
+	GoVersion  string `json:"go_version"`
+	Module     string `json:"module"`
+}
+
+// 构建信息，优先使用 -ldflags 设置的值，没有时使用 Go 在构建时记录的信息
+func GetBuildInfo() BuildInfo {
+	info := BuildInfo{
+		ENV:       ENV,
+		Version:   VERSION,
+		Commit:    COMMIT,
+		BuildTime: BUILDTIME,
+		GoVersion: runtime.Version(),
+	}
+
+	bi, ok := debug.ReadBuildInfo()
+	if !ok {
+		return info
+	}
+
+	info.Module = bi.Main.Path
+	if info.Version == "" && bi.Main.Version != "(devel)" {
+		info.Version = bi.Main.Version
+	}
+	for _, s := range bi.Settings {
+		switch s.Key {
+		case "vcs.revision":
+			if info.Commit == "" {
+				info.Commit = s.Value
+			}
+		case "vcs.time":
+			info.CommitTime = s.Value
+		case "vcs.modified":
+			info.Modified = s.Value == "true"
+		}
+	}
+	return info
+}
@@ -1,70 +1,260 @@
-package base
-
-import (
-	"context"
-	"encoding/json"
-	"errors"
-	"time"
-
-	"github.com/allegro/bigcache/v3"
-	"github.com/samber/oops"
-)
-
-var (
-	cache struct {
-		l Logger
-		c *bigcache.BigCache
-	}
-)
-
-// 初始化缓存。参数 maxCacheSize 单位为 MB
-func InitCache(log Logger, maxCacheSize int) {
-	c, err := bigcache.New(context.Background(), bigcache.Config{
-		Shards:           8,
-		LifeWindow:       3 * time.Minute,
-		CleanWindow:      1 * time.Second,
-		HardMaxCacheSize: maxCacheSize,
-	})
-	if err != nil {
-		panic(err)
-	}
-
-	cache = struct {
-		l Logger
-		c *bigcache.BigCache
-	}{
-		l: log,
-		c: c,
-	}
-}
-
-func Cache[T any](key string, newValue func() (T, error)) (T, error) {
-	var zeroValue T
-
-	cv, err := cache.c.Get(key)
-	if err == nil {
-		var value T
-		if err := json.Unmarshal(cv, &value); err != nil {
-			return zeroValue, oops.Wrap(err)
-		} else {
-			return value, nil
-		}
-	} else if !errors.Is(err, bigcache.ErrEntryNotFound) {
-		return zeroValue, oops.Wrap(err)
-	}
-
-	value, err := newValue()
-	if err != nil {
-		return zeroValue, oops.Wrap(err)
-	}
-
-	jv, err := json.Marshal(value)
-	if err != nil {
-		return zeroValue, oops.Wrap(err)
-	}
-	if err := cache.c.Set(key, jv); err != nil {
-		cache.l.Errorf("设置 %v 缓存错误：%+v", key, oops.Wrap(err))
-	}
-
-	return value, nil
-}
+package base
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/allegro/bigcache/v3"
+	"github.com/samber/oops"
+	"go.uber.org/zap"
+)
+
+type CacheConfig struct {
+	Disabled bool `json:"disabled"` // 禁用缓存，每次都直接调用 newValue，适用于测试
+
+	Shards           int `json:"shards"`              // 分片数量，必须是 2 的幂。默认为 8
+	LifeWindow       int `json:"life_window"`         // 缓存存活时间（秒）。默认为 180
+	CleanWindow      int `json:"clean_window"`        // 清理过期缓存的间隔（秒）。默认为 1
+	MaxEntrySize     int `json:"max_entry_size"`      // 单个缓存的预估大小（字节），仅用于预分配内存
+	HardMaxCacheSize int `json:"hard_max_cache_size"` // 缓存上限（MB）。默认为 0，即不限制
+}
+
+type cacheState struct {
+	l Logger
+	c *bigcache.BigCache
+
+	disabled bool
+}
+
+var (
+	cache     *cacheState
+	cacheLock sync.RWMutex
+)
+
+// 初始化缓存。未初始化时，首次使用缓存会按默认配置自动初始化
+func InitCache(log Logger, conf CacheConfig) error {
+	s, err := newCacheState(log, conf)
+	if err != nil {
+		return oops.Wrap(err)
+	}
+
+	cacheLock.Lock()
+	defer cacheLock.Unlock()
+
+	if cache != nil && cache.c != nil {
+		if err := cache.c.Close(); err != nil {
+			s.log().Warnf("关闭旧缓存错误：%+v", oops.Wrap(err))
+		}
+	}
+	cache = s
+
+	return nil
+}
+
+func newCacheState(log Logger, conf CacheConfig) (*cacheState, error) {
+	if conf.Disabled {
+		return &cacheState{l: log, disabled: true}, nil
+	}
+
+	if conf.Shards <= 0 {
+		conf.Shards = 8
+	}
+	if conf.LifeWindow <= 0 {
+		conf.LifeWindow = 180
+	}
+	if conf.CleanWindow <= 0 {
+		conf.CleanWindow = 1
+	}
+	c, err := bigcache.New(context.Background(), bigcache.Config{
+		Shards:           conf.Shards,
+		LifeWindow:       time.Duration(conf.LifeWindow) * time.Second,
+		CleanWindow:      time.Duration(conf.CleanWindow) * time.Second,
+		MaxEntrySize:     conf.MaxEntrySize,
+		HardMaxCacheSize: conf.HardMaxCacheSize,
+	})
+	if err != nil {
+		return nil, oops.Wrapf(err, "创建缓存失败")
+	}
+
+	return &cacheState{l: log, c: c}, nil
+}
+
+// 获取当前缓存，未初始化时按默认配置初始化
+func getCache() (*cacheState, error) {
+	cacheLock.RLock()
+	s := cache
+	cacheLock.RUnlock()
+
+	if s != nil {
+		return s, nil
+	}
+
+	cacheLock.Lock()
+	defer cacheLock.Unlock()
+
+	if cache != nil {
+		return cache, nil
+	}
+
+	s, err := newCacheState(nil, CacheConfig{})
+	if err != nil {
+		return nil, oops.Wrap(err)
+	}
+	cache = s
+
+	return s, nil
+}
+
+func (s *cacheState) log() Logger {
+	if s.l != nil {
+		return s.l
+	}
+
+	return newDefaultZapLogger(zap.S())
+}
+
+func Cache[T any](key string, newValue func() (T, error)) (T, error) {
+	var zeroValue T
+
+	s, err := getCache()
+	if err != nil {
+		return zeroValue, oops.Wrap(err)
+	}
+
+	if s.disabled {
+		value, err := newValue()
+		if err != nil {
+			return zeroValue, oops.Wrap(err)
+		}
+		return value, nil
+	}
+
+	cv, err := s.c.Get(key)
+	if err == nil {
+		var value T
+		if err := json.Unmarshal(cv, &value); err != nil {
+			return zeroValue, oops.Wrap(err)
+		} else {
+			return value, nil
+		}
+	} else if !errors.Is(err, bigcache.ErrEntryNotFound) {
+		return zeroValue, oops.Wrap(err)
+	}
+
+	value, err := newValue()
+	if err != nil {
+		return zeroValue, oops.Wrap(err)
+	}
+
+	jv, err := json.Marshal(value)
+	if err != nil {
+		return zeroValue, oops.Wrap(err)
+	}
+	if err := s.c.Set(key, jv); err != nil {
+		s.log().Errorf("设置 %v 缓存错误：%+v", key, oops.Wrap(err))
+	}
+
+	return value, nil
+}
+
+// 读取缓存，不存在时 ok 为 false。缓存禁用时总是不存在
+func CacheGet[T any](key string) (value T, ok bool, err error) {
+	s, err := getCache()
+	if err != nil {
+		return value, false, oops.Wrap(err)
+	}
+	if s.disabled {
+		return value, false, nil
+	}
+
+	cv, err := s.c.Get(key)
+	if errors.Is(err, bigcache.ErrEntryNotFound) {
+		return value, false, nil
+	} else if err != nil {
+		return value, false, oops.Wrap(err)
+	}
+
+	if err := json.Unmarshal(cv, &value); err != nil {
+		return value, false, oops.Wrap(err)
+	}
+	return value, true, nil
+}
+
+// 设置缓存，过期时间为 CacheConfig.LifeWindow。缓存禁用时不处理
+func CacheSet(key string, value any) error {
+	s, err := getCache()
+	if err != nil {
+		return oops.Wrap(err)
+	}
+	if s.disabled {
+		return nil
+	}
+
+	jv, err := json.Marshal(value)
+	if err != nil {
+		return oops.Wrap(err)
+	}
+	if err := s.c.Set(key, jv); err != nil {
+		return oops.Wrap(err)
+	}
+	return nil
+}
+
+func CacheDelete(key string) error {
+	s, err := getCache()
+	if err != nil {
+		return oops.Wrap(err)
+	}
+	if s.disabled {
+		return nil
+	}
+
+	if err := s.c.Delete(key); err != nil && !errors.Is(err, bigcache.ErrEntryNotFound) {
+		return oops.Wrap(err)
+	}
+	return nil
+}
+
+type CacheStats struct {
+	Disabled   bool  `json:"disabled"`
+	Len        int   `json:"len"`      // 缓存数量
+	Capacity   int   `json:"capacity"` // 已分配的内存（字节）
+	Hits       int64 `json:"hits"`
+	Misses     int64 `json:"misses"`
+	DelHits    int64 `json:"del_hits"`
+	DelMisses  int64 `json:"del_misses"`
+	Collisions int64 `json:"collisions"`
+}
+
+func GetCacheStats() (CacheStats, error) {
+	s, err := getCache()
+	if err != nil {
+		return CacheStats{}, oops.Wrap(err)
+	}
+
+	if s.disabled {
+		return CacheStats{Disabled: true}, nil
+	}
+
+	stats := s.c.Stats()
+	return CacheStats{
+		Len:        s.c.Len(),
+		Capacity:   s.c.Capacity(),
+		Hits:       stats.Hits,
+		Misses:     stats.Misses,
+		DelHits:    stats.DelHits,
+		DelMisses:  stats.DelMisses,
+		Collisions: stats.Collisions,
+	}, nil
+}
+
+// 缓存健康检查，缓存无法初始化时失败
+func CacheHealthCheck(ctx context.Context) error {
+	if _, err := GetCacheStats(); err != nil {
+		return oops.Wrap(err)
+	}
+	return nil
+}
@@ -37,6 +37,30 @@ func NewBadRequestErrorf(format string, args ...any) error {
 	return NewHttpErrorf(http.StatusBadRequest, format, args...)
 }
 
+func NewUnauthorizedError(err error) error {
+	return NewHttpError(http.StatusUnauthorized, err)
+}
+
+func NewUnauthorizedErrorf(format string, args ...any) error {
+	return NewHttpErrorf(http.StatusUnauthorized, format, args...)
+}
+
+func NewForbiddenError(err error) error {
+	return NewHttpError(http.StatusForbidden, err)
+}
+
+func NewForbiddenErrorf(format string, args ...any) error {
+	return NewHttpErrorf(http.StatusForbidden, format, args...)
+}
+
+func NewTooManyRequestsError(err error) error {
+	return NewHttpError(http.StatusTooManyRequests, err)
+}
+
+func NewTooManyRequestsErrorf(format string, args ...any) error {
+	return NewHttpErrorf(http.StatusTooManyRequests, format, args...)
+}
+
 func NewServiceError(err error) error {
 	return NewHttpError(http.StatusInternalServerError, err)
 }
@@ -1,63 +1,169 @@
-package base
-
-import (
-	"sync"
-
-	"github.com/samber/oops"
-)
-
-// 简单快速的并发执行，需先指定并发数量、消费者、一组生产元素
-func Go[I any, O any](
-	concurrent int,
-	consume func(I) (O, error),
-	items ...I,
-) ([]O, error) {
-	oCh := make(chan O, len(items)+1)
-	errCh := make(chan error, len(items)+1)
-
-	ch := make(chan I, concurrent*2)
-	var wg sync.WaitGroup
-	for i := 0; i < concurrent; i++ {
-		wg.Add(1)
-
-		go func() {
-			Try(func() {
-				for i := range ch {
-					o, err := consume(i)
-
-					if err != nil {
-						errCh <- oops.Wrap(err)
-					} else {
-						oCh <- o
-					}
-				}
-			}).Catch(func(err error) {
-				errCh <- oops.Wrapf(err, "恐慌")
-			}).Finally(func() {
-				wg.Done()
-			}).Do()
-		}()
-	}
-
-	for _, i := range items {
-		ch <- i
-	}
-	close(ch)
-	wg.Wait()
-	close(oCh)
-	close(errCh)
-
-	if len(errCh) > 0 {
-		errs := []error{}
-		for e := range errCh {
-			errs = append(errs, e)
-		}
-		return nil, oops.Wrap(NewMultiError(errs...))
-	} else {
-		os := []O{}
-		for o := range oCh {
-			os = append(os, o)
-		}
-		return os, nil
-	}
-}
+package base
+
+import (
+	"context"
+	"runtime"
+	"sync"
+	"time"
+
+	"github.com/samber/oops"
+)
+
+// 简单快速的并发执行，需先指定并发数量、消费者、一组生产元素
+//   - 结果按元素顺序排列，任一元素出错时只返回错误
+//   - concurrent <= 0 时使用 CPU 数量
+//
+// Deprecated: 使用 GoPool，支持 context、快速失败、部分结果等
+func Go[I any, O any](
+	concurrent int,
+	consume func(I) (O, error),
+	items ...I,
+) ([]O, error) {
+	os, _, err := GoPool(context.Background(), PoolConfig{Concurrent: concurrent}, func(_ context.Context, i I) (O, error) {
+		return consume(i)
+	}, items...)
+	if err != nil {
+		return nil, err
+	}
+	return os, nil
+}
+
+type PoolConfig struct {
+	Concurrent int  // 并发数量。默认为 CPU 数量
+	FailFast   bool // 快速失败。任一元素出错时取消 context，并停止派发剩余元素
+	Rate       int  // 每秒最多派发的元素数量，范围为 0 到 1e9。默认为 0，即不限速
+
+	// 进度回调，每完成一个元素（无论成功失败）调用一次，调用是串行的
+	OnProgress func(done int, total int)
+}
+
+// 并发执行一组元素的工作池
+//   - 返回的结果和错误均按元素顺序排列：成功的元素对应的错误为 nil，失败的元素对应的结果为零值
+//   - 未派发的元素（ctx 结束或快速失败）对应的错误为取消原因
+//   - 有任一元素出错时，最后的 error 为 MultiError，此时前两个返回值仍然有效
+//   - 配置无效时只返回 error
+func GoPool[I any, O any](
+	ctx context.Context,
+	conf PoolConfig,
+	consume func(ctx context.Context, item I) (O, error),
+	items ...I,
+) ([]O, []error, error) {
+	if conf.Rate < 0 || conf.Rate > 1e9 {
+		return nil, nil, oops.Errorf("Rate 必须在 0 到 1e9 之间，当前为 %d", conf.Rate)
+	}
+
+	total := len(items)
+	os := make([]O, total)
+	errs := make([]error, total)
+	if total == 0 {
+		return os, errs, nil
+	}
+
+	concurrent := conf.Concurrent
+	if concurrent <= 0 {
+		concurrent = runtime.NumCPU()
+	}
+	if concurrent > total {
+		concurrent = total
+	}
+
+	ctx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+
+	var tick <-chan time.Time
+	if conf.Rate > 0 {
+		ticker := time.NewTicker(time.Second / time.Duration(conf.Rate))
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+
+	var progressLock sync.Mutex
+	done := 0
+	progress := func() {
+		if conf.OnProgress == nil {
+			return
+		}
+
+		progressLock.Lock()
+		defer progressLock.Unlock()
+
+		done++
+		Try(func() {
+			conf.OnProgress(done, total)
+		}).Do()
+	}
+
+	ch := make(chan int)
+	var wg sync.WaitGroup
+	for i := 0; i < concurrent; i++ {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			for i := range ch {
+				o, err := poolConsume(ctx, consume, items[i])
+				if err != nil {
+					errs[i] = err
+					if conf.FailFast {
+						cancel(err)
+					}
+				} else {
+					os[i] = o
+				}
+
+				progress()
+			}
+		}()
+	}
+
+	dispatched := 0
+dispatch:
+	for i := range items {
+		if tick != nil && i > 0 {
+			select {
+			case <-tick:
+			case <-ctx.Done():
+				break dispatch
+			}
+		}
+
+		select {
+		case ch <- i:
+			dispatched++
+		case <-ctx.Done():
+			break dispatch
+		}
+	}
+	close(ch)
+	wg.Wait()
+
+	for i := dispatched; i < total; i++ {
+		errs[i] = oops.Wrapf(context.Cause(ctx), "未执行")
+	}
+
+	es := []error{}
+	for _, err := range errs {
+		if err != nil {
+			es = append(es, err)
+		}
+	}
+	if len(es) > 0 {
+		return os, errs, oops.Wrap(NewMultiError(es...))
+	}
+
+	return os, errs, nil
+}
+
+func poolConsume[I any, O any](ctx context.Context, consume func(ctx context.Context, item I) (O, error), item I) (o O, err error) {
+	Try(func() {
+		o, err = consume(ctx, item)
+		if err != nil {
+			err = oops.Wrap(err)
+		}
+	}).Catch(func(e error) {
+		err = oops.Wrapf(e, "恐慌")
+	}).Do()
+
+	return
+}
@@ -0,0 +1,101 @@
+package base
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// 幂等记录，Status 为 0 表示请求还在处理中
+type IdempotencyRecord struct {
+	Key         string      `json:"key" gorm:"primaryKey;size:255"`
+	RequestHash string      `json:"request_hash" gorm:"size:64"` // 请求方法、路径、请求体的哈希，用于判断同一个 key 是否用于不同的请求
+	Status      int         `json:"status"`
+	Header      http.Header `json:"header" gorm:"serializer:json"`
+	Body        []byte      `json:"body"`
+	CreatedAt   time.Time   `json:"created_at"`
+	LockedUntil time.Time   `json:"locked_until"` // 处理中的记录在此之后可以被重新占用，比如实例在处理中崩溃，没有释放
+	ExpiresAt   time.Time   `json:"expires_at" gorm:"index"`
+}
+
+func (r *IdempotencyRecord) Completed() bool {
+	return r.Status != 0
+}
+
+// 是否可以被重新占用：已过期，或者处理中但超过了 LockedUntil
+func (r *IdempotencyRecord) Reclaimable(now time.Time) bool {
+	return !now.Before(r.ExpiresAt) || (!r.Completed() && !now.Before(r.LockedUntil))
+}
+
+// 幂等记录存储
+type IdempotencyStore interface {
+	// 原子地占用 key：不存在或可以重新占用（见 IdempotencyRecord.Reclaimable）时保存 record，返回 nil；否则返回已有的记录
+	Claim(ctx context.Context, record IdempotencyRecord) (*IdempotencyRecord, error)
+
+	// 保存响应
+	Complete(ctx context.Context, record IdempotencyRecord) error
+
+	// 删除未完成的记录，处理失败时调用，允许客户端使用同一个 key 重试
+	Release(ctx context.Context, key string) error
+}
+
+// 内存中的幂等记录存储，只在单个实例内有效，重启后丢失
+//   - 记录按 IdempotencyRecord.ExpiresAt 过期，不受 base.Cache 的配置影响
+//   - 过期的记录每分钟清理一次
+type MemoryIdempotencyStore struct {
+	lock      sync.Mutex
+	records   map[string]IdempotencyRecord
+	cleanedAt time.Time
+}
+
+func NewMemoryIdempotencyStore() *MemoryIdempotencyStore {
+	return &MemoryIdempotencyStore{records: map[string]IdempotencyRecord{}, cleanedAt: time.Now()}
+}
+
+// 清理过期的记录，调用方持有锁
+func (s *MemoryIdempotencyStore) clean(now time.Time) {
+	if now.Sub(s.cleanedAt) < time.Minute {
+		return
+	}
+	s.cleanedAt = now
+
+	for key, record := range s.records {
+		if !now.Before(record.ExpiresAt) {
+			delete(s.records, key)
+		}
+	}
+}
+
+func (s *MemoryIdempotencyStore) Claim(ctx context.Context, record IdempotencyRecord) (*IdempotencyRecord, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	now := time.Now()
+	s.clean(now)
+
+	if existing, ok := s.records[record.Key]; ok && !existing.Reclaimable(now) {
+		return &existing, nil
+	}
+
+	s.records[record.Key] = record
+	return nil, nil
+}
+
+func (s *MemoryIdempotencyStore) Complete(ctx context.Context, record IdempotencyRecord) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.records[record.Key] = record
+	return nil
+}
+
+func (s *MemoryIdempotencyStore) Release(ctx context.Context, key string) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if existing, ok := s.records[key]; ok && !existing.Completed() {
+		delete(s.records, key)
+	}
+	return nil
+}
@@ -0,0 +1,212 @@
+package base
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"strconv"
+	"strings"
+
+	"github.com/samber/oops"
+)
+
+const (
+	FilterEq   = "eq"
+	FilterNe   = "ne"
+	FilterGt   = "gt"
+	FilterGte  = "gte"
+	FilterLt   = "lt"
+	FilterLte  = "lte"
+	FilterLike = "like"
+	FilterIn   = "in"
+)
+
+// 列表查询参数
+type PageQuery struct {
+	Page     int      `json:"page"`      // 页码，从 1 开始
+	PageSize int      `json:"page_size"` // 每页数量
+	Cursor   string   `json:"cursor"`    // 游标，使用游标分页时忽略页码
+	Sorts    []Sort   `json:"sorts"`
+	Filters  []Filter `json:"filters"`
+}
+
+type Sort struct {
+	Field string `json:"field"`
+	Desc  bool   `json:"desc"`
+}
+
+type Filter struct {
+	Field  string   `json:"field"`
+	Op     string   `json:"op"`
+	Values []string `json:"values"` // in 有多个值，其他操作只有一个值
+}
+
+// 分页结果
+type Page[T any] struct {
+	Items      []T    `json:"items"`
+	Total      int64  `json:"total"` // 未统计时为 -1
+	Page       int    `json:"page,omitempty"`
+	PageSize   int    `json:"page_size"`
+	NextCursor string `json:"next_cursor,omitempty"` // 没有下一页时为空
+}
+
+// 列表接口的规则，只有白名单内的字段才能排序和过滤，字段名会映射为数据库列名，避免 SQL 注入
+type PageRule struct {
+	DefaultPageSize int // 默认为 20
+	MaxPageSize     int // 默认为 100
+
+	Sorts       map[string]string // 可排序的字段 -> 列名
+	DefaultSort []Sort            // 未指定排序时使用
+
+	Filters map[string]FilterRule // 可过滤的字段
+
+	// 游标分页使用的字段（需在 Sorts 中，且值唯一，比如 id），为空时使用页码分页
+	CursorField string
+	CursorDesc  bool // 游标字段倒序
+
+	SkipTotal bool // 不统计总数
+}
+
+type FilterRule struct {
+	Column string   // 列名
+	Ops    []string // 允许的操作。默认为 eq
+}
+
+// 校验并补全查询参数，不合法时返回 400 错误
+func (r PageRule) Normalize(q PageQuery) (PageQuery, error) {
+	if r.DefaultPageSize <= 0 {
+		r.DefaultPageSize = 20
+	}
+	if r.MaxPageSize <= 0 {
+		r.MaxPageSize = 100
+	}
+
+	if q.PageSize <= 0 {
+		q.PageSize = r.DefaultPageSize
+	}
+	if q.PageSize > r.MaxPageSize {
+		return q, NewBadRequestErrorf("每页数量不能超过 %d", r.MaxPageSize)
+	}
+	if q.Page <= 0 {
+		q.Page = 1
+	}
+
+	if r.CursorField != "" {
+		if _, ok := r.Sorts[r.CursorField]; !ok {
+			return q, oops.Errorf("游标字段 %v 不在排序白名单中", r.CursorField)
+		}
+		// 游标分页只能按游标字段排序
+		q.Page = 0
+		q.Sorts = []Sort{{Field: r.CursorField, Desc: r.CursorDesc}}
+	} else if q.Cursor != "" {
+		return q, NewBadRequestErrorf("不支持游标分页")
+	}
+
+	if len(q.Sorts) == 0 {
+		q.Sorts = r.DefaultSort
+	}
+	for _, s := range q.Sorts {
+		if _, ok := r.Sorts[s.Field]; !ok {
+			return q, NewBadRequestErrorf("不支持按 %v 排序", s.Field)
+		}
+	}
+
+	for _, f := range q.Filters {
+		fr, ok := r.Filters[f.Field]
+		if !ok {
+			return q, NewBadRequestErrorf("不支持按 %v 过滤", f.Field)
+		}
+
+		ops := fr.Ops
+		if len(ops) == 0 {
+			ops = []string{FilterEq}
+		}
+		allowed := false
+		for _, op := range ops {
+			if op == f.Op {
+				allowed = true
+				break
+			}
+		}
+		if !allowed {
+			return q, NewBadRequestErrorf("字段 %v 不支持 %v 过滤", f.Field, f.Op)
+		}
+
+		if len(f.Values) == 0 || (f.Op != FilterIn && len(f.Values) != 1) {
+			return q, NewBadRequestErrorf("字段 %v 的过滤值不合法", f.Field)
+		}
+	}
+
+	return q, nil
+}
+
+// 解析排序表达式，比如 -created_at,name 表示按 created_at 倒序、name 正序
+func ParseSorts(expr string) []Sort {
+	sorts := []Sort{}
+	for _, s := range strings.Split(expr, ",") {
+		s = strings.TrimSpace(s)
+		if s == "" {
+			continue
+		}
+
+		if strings.HasPrefix(s, "-") {
+			sorts = append(sorts, Sort{Field: s[1:], Desc: true})
+		} else {
+			sorts = append(sorts, Sort{Field: strings.TrimPrefix(s, "+")})
+		}
+	}
+	return sorts
+}
+
+// 解析过滤表达式 field:op:value，op 省略时为 eq，in 的多个值用 | 分隔，比如 status:in:active|locked
+func ParseFilter(expr string) (Filter, error) {
+	parts := strings.SplitN(expr, ":", 3)
+	switch len(parts) {
+	case 2:
+		return Filter{Field: parts[0], Op: FilterEq, Values: []string{parts[1]}}, nil
+	case 3:
+		f := Filter{Field: parts[0], Op: parts[1], Values: []string{parts[2]}}
+		if f.Op == FilterIn {
+			f.Values = strings.Split(parts[2], "|")
+		}
+		return f, nil
+	default:
+		return Filter{}, NewBadRequestErrorf("过滤表达式 %v 不合法", expr)
+	}
+}
+
+// 游标为 JSON 值的 base64url 编码，对客户端不透明
+func EncodeCursor(value any) (string, error) {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return "", oops.Wrap(err)
+	}
+	return base64.RawURLEncoding.EncodeToString(data), nil
+}
+
+func DecodeCursor(cursor string) (any, error) {
+	data, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, NewBadRequestErrorf("游标不合法")
+	}
+
+	var value any
+	d := json.NewDecoder(strings.NewReader(string(data)))
+	d.UseNumber()
+	if err := d.Decode(&value); err != nil {
+		return nil, NewBadRequestErrorf("游标不合法")
+	}
+
+	// 数字游标转为 int64 或 float64，便于数据库比较
+	switch v := value.(type) {
+	case json.Number:
+		if i, err := strconv.ParseInt(v.String(), 10, 64); err == nil {
+			return i, nil
+		}
+		f, _ := v.Float64()
+		return f, nil
+	case string, bool:
+		return v, nil
+	default:
+		return nil, NewBadRequestErrorf("游标不合法")
+	}
+}
@@ -1,59 +1,259 @@
-package base
-
-import (
-	"context"
-	"fmt"
-	"strings"
-
-	"github.com/imroc/req/v3"
-)
-
-func NewReqClient(log Logger, traceContextKey any) *req.Client {
-	return req.C().WrapRoundTripFunc(NewReqLogRoundTripFunc(log, traceContextKey))
-}
-
-func NewReqLogRoundTripFunc(log Logger, traceContextKey any) req.RoundTripWrapperFunc {
-	logReq := func(req *req.Request) {
-		log = log.WithTrace(req.Context(), traceContextKey).WithTag("Req")
-
-		sb := strings.Builder{}
-		sb.WriteString(fmt.Sprintf("REQ:%v %v\n", req.Method, req.URL))
-		for k, v := range req.Headers {
-			sb.WriteString(fmt.Sprintf("  %v: %v\n", k, v))
-		}
-		sb.WriteString(fmt.Sprintf("\n%v\n", string(req.Body)))
-		log.Debug(sb.String())
-	}
-
-	logResp := func(resp *req.Response, err error) {
-		log = log.WithTrace(resp.Request.Context(), traceContextKey).WithTag("Req")
-
-		sb := strings.Builder{}
-		sb.WriteString(fmt.Sprintf("RESP:%v %v\n", resp.Request.Method, resp.Request.URL))
-		if err != nil {
-			sb.WriteString(fmt.Sprintf("\n%+v", err))
-			log.Error(sb.String())
-		} else {
-			sb.WriteString(fmt.Sprintf("%d %v\n", resp.StatusCode, resp.TotalTime()))
-			for k, v := range resp.Header {
-				sb.WriteString(fmt.Sprintf("  %v: %v\n", k, v))
-			}
-			sb.WriteString(fmt.Sprintf("\n%v", resp.String()))
-			log.Debug(sb.String())
-		}
-	}
-
-	return func(rt req.RoundTripper) req.RoundTripFunc {
-		return func(req *req.Request) (resp *req.Response, err error) {
-			if traceID := req.Context().Value(traceContextKey); traceID == nil {
-				traceID = NewNanoID()
-				req.SetContext(context.WithValue(req.Context(), traceContextKey, traceID))
-			}
-
-			logReq(req)
-			resp, err = rt.RoundTrip(req)
-			logResp(resp, err)
-			return
-		}
-	}
-}
+package base
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/imroc/req/v3"
+	"github.com/samber/oops"
+)
+
+const (
+	// 传递剩余时间（毫秒）的请求头
+	ReqDeadlineHeader = "X-Request-Timeout"
+)
+
+type ReqOption func(o *reqOptions)
+
+type reqOptions struct {
+	retry          *RetryPolicy
+	breaker        *BreakerGroup
+	deadlineHeader string
+}
+
+// 按策略重试请求，每次尝试都会记录日志
+//   - 网络错误、可重试的状态码（见 IsRetryableStatusCode）会重试
+//   - 默认只重试幂等的请求，见 RetryPolicy.RetryNonIdempotent
+//   - 响应头 Retry-After 会作为最小等待时间
+func WithReqRetry(policy RetryPolicy) ReqOption {
+	return func(o *reqOptions) {
+		o.retry = &policy
+	}
+}
+
+// 按下游主机熔断，熔断器打开时请求直接返回 503 错误
+//   - 网络错误、5xx、429 等响应计为失败
+func WithReqBreaker(conf BreakerConfig) ReqOption {
+	return func(o *reqOptions) {
+		o.breaker = NewBreakerGroup(conf)
+	}
+}
+
+// 使用已有的熔断器组，便于多个客户端共享熔断状态
+func WithReqBreakerGroup(group *BreakerGroup) ReqOption {
+	return func(o *reqOptions) {
+		o.breaker = group
+	}
+}
+
+// 通过请求头把 context 的剩余时间（毫秒）传给下游，header 为空时使用 ReqDeadlineHeader
+func WithReqDeadlineHeader(header string) ReqOption {
+	return func(o *reqOptions) {
+		if header == "" {
+			header = ReqDeadlineHeader
+		}
+		o.deadlineHeader = header
+	}
+}
+
+func NewReqClient(log Logger, traceContextKey any, opts ...ReqOption) *req.Client {
+	o := reqOptions{}
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	c := req.C().WrapRoundTripFunc(NewReqLogRoundTripFunc(log, traceContextKey))
+
+	// 后包装的在外层：重试 -> 熔断 -> 截止时间 -> 日志，每次尝试都会经过熔断器并记录日志
+	if o.deadlineHeader != "" {
+		c.WrapRoundTripFunc(NewReqDeadlineRoundTripFunc(o.deadlineHeader))
+	}
+	if o.breaker != nil {
+		c.WrapRoundTripFunc(NewReqBreakerRoundTripFunc(o.breaker, nil))
+	}
+	if o.retry != nil {
+		policy := *o.retry
+		if policy.Log == nil {
+			policy.Log = log
+		}
+		if policy.TraceContextKey == nil {
+			policy.TraceContextKey = traceContextKey
+		}
+		c.WrapRoundTripFunc(NewReqRetryRoundTripFunc(policy))
+	}
+
+	return c
+}
+
+func NewReqLogRoundTripFunc(log Logger, traceContextKey any) req.RoundTripWrapperFunc {
+	logReq := func(req *req.Request) {
+		log = log.WithTrace(req.Context(), traceContextKey).WithTag("Req")
+
+		sb := strings.Builder{}
+		sb.WriteString(fmt.Sprintf("REQ:%v %v\n", req.Method, req.URL))
+		for k, v := range req.Headers {
+			sb.WriteString(fmt.Sprintf("  %v: %v\n", k, v))
+		}
+		sb.WriteString(fmt.Sprintf("\n%v\n", string(req.Body)))
+		log.Debug(sb.String())
+	}
+
+	logResp := func(resp *req.Response, err error) {
+		log = log.WithTrace(resp.Request.Context(), traceContextKey).WithTag("Req")
+
+		sb := strings.Builder{}
+		sb.WriteString(fmt.Sprintf("RESP:%v %v\n", resp.Request.Method, resp.Request.URL))
+		if err != nil {
+			sb.WriteString(fmt.Sprintf("\n%+v", err))
+			log.Error(sb.String())
+		} else {
+			sb.WriteString(fmt.Sprintf("%d %v\n", resp.StatusCode, resp.TotalTime()))
+			for k, v := range resp.Header {
+				sb.WriteString(fmt.Sprintf("  %v: %v\n", k, v))
+			}
+			sb.WriteString(fmt.Sprintf("\n%v", resp.String()))
+			log.Debug(sb.String())
+		}
+	}
+
+	return func(rt req.RoundTripper) req.RoundTripFunc {
+		return func(req *req.Request) (resp *req.Response, err error) {
+			if traceID := req.Context().Value(traceContextKey); traceID == nil {
+				traceID = NewNanoID()
+				req.SetContext(context.WithValue(req.Context(), traceContextKey, traceID))
+			}
+
+			logReq(req)
+			resp, err = rt.RoundTrip(req)
+			logResp(resp, err)
+			return
+		}
+	}
+}
+
+func NewReqRetryRoundTripFunc(policy RetryPolicy) req.RoundTripWrapperFunc {
+	return func(rt req.RoundTripper) req.RoundTripFunc {
+		return func(req *req.Request) (resp *req.Response, err error) {
+			if !policy.RetryNonIdempotent && !isIdempotentRequest(req.Method, req.Headers) {
+				return rt.RoundTrip(req)
+			}
+
+			statusErr := false
+
+			err = Retry(req.Context(), policy, func(ctx context.Context) error {
+				statusErr = false
+
+				resp, err = rt.RoundTrip(req)
+				if err != nil {
+					return err
+				}
+
+				if code := resp.StatusCode; IsRetryableStatusCode(code) {
+					statusErr = true
+
+					err := NewHttpErrorf(code, "响应状态码 %d", code)
+					if after := parseRetryAfter(resp.Header.Get("Retry-After")); after > 0 {
+						err = NewRetryAfterError(err, after)
+					}
+					return err
+				}
+
+				return nil
+			})
+
+			// 状态码错误时仍然返回最后一次的响应，由调用方自行处理
+			if statusErr {
+				return resp, nil
+			}
+			return resp, err
+		}
+	}
+}
+
+// 重复发送不会产生额外副作用的请求
+func isIdempotentRequest(method string, header http.Header) bool {
+	switch strings.ToUpper(method) {
+	case "", http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodPut, http.MethodDelete, http.MethodTrace:
+		return true
+	}
+	return header.Get("Idempotency-Key") != ""
+}
+
+func parseRetryAfter(v string) time.Duration {
+	if v == "" {
+		return 0
+	}
+
+	if seconds, err := strconv.Atoi(v); err == nil {
+		return time.Duration(seconds) * time.Second
+	}
+	if t, err := http.ParseTime(v); err == nil {
+		return time.Until(t)
+	}
+	return 0
+}
+
+// 熔断请求，key 为 nil 时按主机区分熔断器
+func NewReqBreakerRoundTripFunc(group *BreakerGroup, key func(req *req.Request) string) req.RoundTripWrapperFunc {
+	if key == nil {
+		key = func(req *req.Request) string {
+			return req.URL.Host
+		}
+	}
+
+	return func(rt req.RoundTripper) req.RoundTripFunc {
+		return func(r *req.Request) (resp *req.Response, err error) {
+			done, err := group.Get(key(r)).Allow()
+			if err != nil {
+				return &req.Response{Request: r, Err: err}, err
+			}
+
+			resp, err = rt.RoundTrip(r)
+			if err != nil {
+				done(err)
+			} else if code := resp.StatusCode; IsRetryableStatusCode(code) {
+				done(NewHttpErrorf(code, "响应状态码 %d", code))
+			} else {
+				done(nil)
+			}
+			return resp, err
+		}
+	}
+}
+
+// 传递 context 的剩余时间，已经超时的请求不再发出
+func NewReqDeadlineRoundTripFunc(header string) req.RoundTripWrapperFunc {
+	return func(rt req.RoundTripper) req.RoundTripFunc {
+		return func(r *req.Request) (resp *req.Response, err error) {
+			if deadline, ok := r.Context().Deadline(); ok {
+				remaining := time.Until(deadline)
+				if remaining <= 0 {
+					err = oops.Wrapf(context.DeadlineExceeded, "请求 %v 前已超时", r.URL)
+					return &req.Response{Request: r, Err: err}, err
+				}
+
+				r.SetHeader(header, strconv.FormatInt(remaining.Milliseconds(), 10))
+			}
+
+			return rt.RoundTrip(r)
+		}
+	}
+}
+
+// 探测外部依赖的健康检查，请求 url 并要求响应 2xx，可用于 api.Health
+func ReqHealthCheck(client *req.Client, method string, url string) func(ctx context.Context) error {
+	return func(ctx context.Context) error {
+		resp, err := client.R().SetContext(ctx).Send(method, url)
+		if err != nil {
+			return oops.Wrapf(err, "请求 %v 失败", url)
+		}
+		if !resp.IsSuccessState() {
+			return NewHttpErrorf(resp.StatusCode, "请求 %v 响应状态码 %d", url, resp.StatusCode)
+		}
+		return nil
+	}
+}
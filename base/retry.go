@@ -0,0 +1,233 @@
+package base
+
+import (
+	"context"
+	"errors"
+	"math"
+	"math/rand"
+	"net/http"
+	"time"
+
+	"github.com/samber/oops"
+)
+
+type RetryPolicy struct {
+	MaxAttempts     int           // 最大尝试次数（含首次）。默认为 3
+	InitialInterval time.Duration // 首次重试前的等待时间。默认为 100ms
+	MaxInterval     time.Duration // 最大等待时间。默认为 10s
+	Multiplier      float64       // 等待时间的增长倍数。默认为 2
+	Jitter          float64       // 随机抖动比例（0~1），实际等待时间在 [interval*(1-Jitter), interval*(1+Jitter)] 之间。默认为 0，即不抖动
+	MaxElapsedTime  time.Duration // 总时间预算，超出后不再重试。默认为 0，即不限制
+
+	// 判断错误是否可以重试。默认为 IsRetryableError
+	Retryable func(err error) bool
+
+	// 用于 HTTP 请求时，是否重试 POST、PATCH 等非幂等的请求。默认只重试 GET、HEAD、OPTIONS、PUT、DELETE 以及带 Idempotency-Key 的请求
+	RetryNonIdempotent bool
+
+	// 记录每次失败的尝试，为 nil 时不记录
+	Log             Logger
+	TraceContextKey any
+
+	// 每次重试前调用，attempt 为已失败的次数
+	OnRetry func(attempt int, err error, wait time.Duration)
+}
+
+// 常用的重试策略：3 次尝试，100ms 起指数退避，20% 抖动
+func DefaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{
+		MaxAttempts:     3,
+		InitialInterval: 100 * time.Millisecond,
+		MaxInterval:     10 * time.Second,
+		Multiplier:      2,
+		Jitter:          0.2,
+	}
+}
+
+func (p RetryPolicy) withDefault() RetryPolicy {
+	if p.MaxAttempts <= 0 {
+		p.MaxAttempts = 3
+	}
+	if p.InitialInterval <= 0 {
+		p.InitialInterval = 100 * time.Millisecond
+	}
+	if p.MaxInterval <= 0 {
+		p.MaxInterval = 10 * time.Second
+	}
+	if p.Multiplier < 1 {
+		p.Multiplier = 2
+	}
+	if p.Jitter < 0 {
+		p.Jitter = 0
+	}
+	if p.Jitter > 1 {
+		p.Jitter = 1
+	}
+	if p.Retryable == nil {
+		p.Retryable = IsRetryableError
+	}
+	return p
+}
+
+// 第 attempt 次失败后的等待时间
+func (p RetryPolicy) backoff(attempt int) time.Duration {
+	interval := float64(p.InitialInterval) * math.Pow(p.Multiplier, float64(attempt-1))
+	if interval > float64(p.MaxInterval) {
+		interval = float64(p.MaxInterval)
+	}
+
+	if p.Jitter > 0 {
+		delta := interval * p.Jitter
+		interval = interval - delta + rand.Float64()*2*delta
+	}
+
+	return time.Duration(interval)
+}
+
+// 按策略重试 fn，直到成功、错误不可重试、次数用尽、超出时间预算或 ctx 结束
+func Retry(ctx context.Context, policy RetryPolicy, fn func(ctx context.Context) error) error {
+	_, err := RetryValue(ctx, policy, func(ctx context.Context) (struct{}, error) {
+		return struct{}{}, fn(ctx)
+	})
+	return err
+}
+
+// 同 Retry，但返回 fn 的结果
+func RetryValue[T any](ctx context.Context, policy RetryPolicy, fn func(ctx context.Context) (T, error)) (T, error) {
+	policy = policy.withDefault()
+
+	var log Logger
+	if policy.Log != nil {
+		log = policy.Log.WithTrace(ctx, policy.TraceContextKey).WithTag("Retry")
+	}
+
+	start := time.Now()
+	for attempt := 1; ; attempt++ {
+		value, err := fn(ctx)
+		if err == nil {
+			return value, nil
+		}
+
+		if ctx.Err() != nil || !policy.Retryable(err) {
+			return value, oops.Wrap(err)
+		}
+		if attempt >= policy.MaxAttempts {
+			return value, oops.Wrapf(err, "尝试 %d 次后仍失败", attempt)
+		}
+
+		wait := policy.backoff(attempt)
+		var ra interface{ RetryAfter() time.Duration }
+		if errors.As(err, &ra) && ra.RetryAfter() > wait {
+			wait = ra.RetryAfter()
+		}
+
+		if policy.MaxElapsedTime > 0 && time.Since(start)+wait > policy.MaxElapsedTime {
+			return value, oops.Wrapf(err, "尝试 %d 次后超出时间预算 %v", attempt, policy.MaxElapsedTime)
+		}
+
+		if log != nil {
+			log.Warnf("第 %d 次尝试失败，%v 后重试：%+v", attempt, wait, err)
+		}
+		if policy.OnRetry != nil {
+			policy.OnRetry(attempt, err, wait)
+		}
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return value, oops.Wrapf(context.Cause(ctx), "等待重试时结束，最后一次错误：%v", err)
+		}
+	}
+}
+
+type permanentError struct {
+	error
+}
+
+func (e *permanentError) Unwrap() error {
+	return e.error
+}
+
+// 标记错误不可重试
+func NewPermanentError(err error) error {
+	if err == nil {
+		return nil
+	}
+	return oops.Wrap(&permanentError{err})
+}
+
+type retryAfterError struct {
+	error
+	after time.Duration
+}
+
+func (e *retryAfterError) Unwrap() error {
+	return e.error
+}
+
+func (e *retryAfterError) RetryAfter() time.Duration {
+	return e.after
+}
+
+// 标记错误在至少 after 之后才能重试，比如响应头 Retry-After 指定的时间
+func NewRetryAfterError(err error, after time.Duration) error {
+	if err == nil {
+		return nil
+	}
+	return oops.Wrap(&retryAfterError{err, after})
+}
+
+// 默认的可重试错误判断
+//   - NewPermanentError 标记的错误、熔断器打开：不重试
+//   - MultiError：所有子错误都可重试时才重试
+//   - HttpError：408、425、429、500、502、503、504 重试，其他状态码不重试
+//   - 其他错误：重试
+func IsRetryableError(err error) bool {
+	if err == nil {
+		return false
+	}
+
+	var pe *permanentError
+	if errors.As(err, &pe) {
+		return false
+	}
+
+	if errors.Is(err, ErrBreakerOpen) {
+		return false
+	}
+
+	var me *MultiError
+	if errors.As(err, &me) && !me.IsZero() {
+		for _, e := range me.Erros() {
+			if !IsRetryableError(e) {
+				return false
+			}
+		}
+		return true
+	}
+
+	var he *HttpError
+	if errors.As(err, &he) {
+		return IsRetryableStatusCode(he.Code())
+	}
+
+	return true
+}
+
+// 可重试的 HTTP 状态码
+func IsRetryableStatusCode(code int) bool {
+	switch code {
+	case http.StatusRequestTimeout,
+		http.StatusTooEarly,
+		http.StatusTooManyRequests,
+		http.StatusInternalServerError,
+		http.StatusBadGateway,
+		http.StatusServiceUnavailable,
+		http.StatusGatewayTimeout:
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,169 @@
+package base
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/samber/oops"
+)
+
+var (
+	ErrFileNotFound = errors.New("文件不存在")
+)
+
+type FileInfo struct {
+	Key         string    `json:"key"`
+	Size        int64     `json:"size"`
+	ContentType string    `json:"content_type"`
+	ModTime     time.Time `json:"mod_time"`
+	ETag        string    `json:"etag"`           // 不带引号
+	Hash        string    `json:"hash,omitempty"` // 内容的 SHA-256，只在保存时返回
+}
+
+// 文件存储，key 为 / 分隔的相对路径
+type Storage interface {
+	// 保存文件，流式读取 r，不会整个读入内存
+	Put(ctx context.Context, key string, r io.Reader, contentType string) (FileInfo, error)
+
+	// 打开文件，支持 Seek 以便响应范围请求。文件不存在时返回 ErrFileNotFound
+	Open(ctx context.Context, key string) (io.ReadSeekCloser, FileInfo, error)
+
+	Delete(ctx context.Context, key string) error
+}
+
+// 本地文件系统存储
+type LocalStorage struct {
+	dir string
+}
+
+func NewLocalStorage(dir string) (*LocalStorage, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, oops.Wrapf(err, "创建存储目录失败")
+	}
+	return &LocalStorage{dir: dir}, nil
+}
+
+// key 转为目录内的路径，不允许通过 .. 访问目录外的文件
+func (s *LocalStorage) path(key string) (string, error) {
+	key = path.Clean("/" + strings.ReplaceAll(key, "\\", "/"))
+	if key == "/" {
+		return "", NewBadRequestErrorf("文件路径不能为空")
+	}
+	return filepath.Join(s.dir, filepath.FromSlash(key)), nil
+}
+
+func (s *LocalStorage) Put(ctx context.Context, key string, r io.Reader, contentType string) (FileInfo, error) {
+	p, err := s.path(key)
+	if err != nil {
+		return FileInfo{}, oops.Wrap(err)
+	}
+	if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+		return FileInfo{}, oops.Wrap(err)
+	}
+
+	// 先写临时文件，成功后再重命名，避免留下不完整的文件
+	f, err := os.CreateTemp(filepath.Dir(p), ".upload-*")
+	if err != nil {
+		return FileInfo{}, oops.Wrap(err)
+	}
+	defer os.Remove(f.Name())
+	defer f.Close()
+
+	h := sha256.New()
+	size, err := io.Copy(io.MultiWriter(f, h), contextReader{ctx, r})
+	if err != nil {
+		return FileInfo{}, oops.Wrapf(err, "写入文件失败")
+	}
+	if err := f.Close(); err != nil {
+		return FileInfo{}, oops.Wrap(err)
+	}
+	if err := os.Rename(f.Name(), p); err != nil {
+		return FileInfo{}, oops.Wrap(err)
+	}
+
+	stat, err := os.Stat(p)
+	if err != nil {
+		return FileInfo{}, oops.Wrap(err)
+	}
+
+	return FileInfo{
+		Key:         key,
+		Size:        size,
+		ContentType: contentType,
+		ModTime:     stat.ModTime(),
+		ETag:        localETag(stat),
+		Hash:        hex.EncodeToString(h.Sum(nil)),
+	}, nil
+}
+
+// 本地文件不保存哈希，使用大小和修改时间作为 ETag
+func localETag(stat fs.FileInfo) string {
+	return fmt.Sprintf("%x-%x", stat.Size(), stat.ModTime().UnixNano())
+}
+
+func (s *LocalStorage) Open(ctx context.Context, key string) (io.ReadSeekCloser, FileInfo, error) {
+	p, err := s.path(key)
+	if err != nil {
+		return nil, FileInfo{}, oops.Wrap(err)
+	}
+
+	f, err := os.Open(p)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, FileInfo{}, NewHttpError(http.StatusNotFound, ErrFileNotFound)
+	} else if err != nil {
+		return nil, FileInfo{}, oops.Wrap(err)
+	}
+
+	stat, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, FileInfo{}, oops.Wrap(err)
+	}
+	if stat.IsDir() {
+		f.Close()
+		return nil, FileInfo{}, NewHttpError(http.StatusNotFound, ErrFileNotFound)
+	}
+
+	return f, FileInfo{
+		Key:     key,
+		Size:    stat.Size(),
+		ModTime: stat.ModTime(),
+		ETag:    localETag(stat),
+	}, nil
+}
+
+func (s *LocalStorage) Delete(ctx context.Context, key string) error {
+	p, err := s.path(key)
+	if err != nil {
+		return oops.Wrap(err)
+	}
+
+	if err := os.Remove(p); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return oops.Wrap(err)
+	}
+	return nil
+}
+
+// 读取时检查 context，上传被取消时及时结束
+type contextReader struct {
+	ctx context.Context
+	r   io.Reader
+}
+
+func (r contextReader) Read(p []byte) (int, error) {
+	if err := r.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return r.r.Read(p)
+}
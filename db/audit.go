@@ -0,0 +1,63 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"sgo-api/base"
+
+	"github.com/samber/oops"
+	"gorm.io/gorm"
+)
+
+// 把审计记录写入数据库表
+type AuditSink struct {
+	tx    *gorm.DB
+	table string
+}
+
+// table 为空时使用 audit_records，migrate 为 true 时自动建表
+func NewAuditSink(tx *gorm.DB, table string, migrate bool) (*AuditSink, error) {
+	if table == "" {
+		table = "audit_records"
+	}
+
+	s := &AuditSink{tx: tx, table: table}
+	if migrate {
+		if err := tx.Table(table).AutoMigrate(&base.AuditRecord{}); err != nil {
+			return nil, oops.Wrapf(err, "创建审计表失败")
+		}
+	}
+	return s, nil
+}
+
+func (s *AuditSink) Write(ctx context.Context, record base.AuditRecord) error {
+	if err := s.tx.WithContext(ctx).Table(s.table).Create(&record).Error; err != nil {
+		return oops.Wrap(err)
+	}
+	return nil
+}
+
+func (s *AuditSink) Last(ctx context.Context, chain string) (*base.AuditRecord, error) {
+	var record base.AuditRecord
+	err := s.tx.WithContext(ctx).Table(s.table).Where("chain = ?", chain).Order("seq DESC").Take(&record).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	} else if err != nil {
+		return nil, oops.Wrap(err)
+	}
+	return &record, nil
+}
+
+// 按 Seq 升序读取链上 [fromSeq, toSeq] 的记录，toSeq <= 0 时读到最后，用于 base.VerifyAuditChain
+func (s *AuditSink) Read(ctx context.Context, chain string, fromSeq int64, toSeq int64) ([]base.AuditRecord, error) {
+	q := s.tx.WithContext(ctx).Table(s.table).Where("chain = ? AND seq >= ?", chain, fromSeq)
+	if toSeq > 0 {
+		q = q.Where("seq <= ?", toSeq)
+	}
+
+	records := []base.AuditRecord{}
+	if err := q.Order("seq ASC").Find(&records).Error; err != nil {
+		return nil, oops.Wrap(err)
+	}
+	return records, nil
+}
@@ -0,0 +1,22 @@
+package db
+
+import (
+	"context"
+
+	"github.com/samber/oops"
+	"gorm.io/gorm"
+)
+
+// 数据库健康检查，Ping 底层连接，可用于 api.Health
+func HealthCheck(tx *gorm.DB) func(ctx context.Context) error {
+	return func(ctx context.Context) error {
+		sqlDB, err := tx.DB()
+		if err != nil {
+			return oops.Wrap(err)
+		}
+		if err := sqlDB.PingContext(ctx); err != nil {
+			return oops.Wrapf(err, "数据库连接失败")
+		}
+		return nil
+	}
+}
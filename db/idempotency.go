@@ -0,0 +1,84 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sgo-api/base"
+	"time"
+
+	"github.com/samber/oops"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// key 是部分数据库的保留字，通过 clause.Column 按方言加引号
+var idempotencyKeyColumn = clause.Column{Name: "key"}
+
+// 把幂等记录保存到数据库表，多实例共享
+type IdempotencyStore struct {
+	tx    *gorm.DB
+	table string
+}
+
+// table 为空时使用 idempotency_records，migrate 为 true 时自动建表
+func NewIdempotencyStore(tx *gorm.DB, table string, migrate bool) (*IdempotencyStore, error) {
+	if table == "" {
+		table = "idempotency_records"
+	}
+
+	s := &IdempotencyStore{tx: tx, table: table}
+	if migrate {
+		if err := tx.Table(table).AutoMigrate(&base.IdempotencyRecord{}); err != nil {
+			return nil, oops.Wrapf(err, "创建幂等表失败")
+		}
+	}
+	return s, nil
+}
+
+func (s *IdempotencyStore) Claim(ctx context.Context, record base.IdempotencyRecord) (*base.IdempotencyRecord, error) {
+	tx := s.tx.WithContext(ctx).Table(s.table)
+
+	// 先删除已过期或处理超时的记录，再依靠主键冲突保证只有一个请求能占用
+	now := time.Now()
+	if err := tx.Where(clause.Eq{Column: idempotencyKeyColumn, Value: record.Key}).
+		Where("expires_at <= ? OR (status = 0 AND locked_until <= ?)", now, now).
+		Delete(&base.IdempotencyRecord{}).Error; err != nil {
+		return nil, oops.Wrap(err)
+	}
+
+	result := s.tx.WithContext(ctx).Table(s.table).Clauses(clause.OnConflict{DoNothing: true}).Create(&record)
+	if result.Error != nil {
+		return nil, oops.Wrap(result.Error)
+	}
+	if result.RowsAffected > 0 {
+		return nil, nil
+	}
+
+	var existing base.IdempotencyRecord
+	err := s.tx.WithContext(ctx).Table(s.table).Where(clause.Eq{Column: idempotencyKeyColumn, Value: record.Key}).Take(&existing).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		// 刚被释放，让客户端重试
+		return nil, base.NewHttpErrorf(http.StatusConflict, "请求正在处理中，请稍后重试")
+	} else if err != nil {
+		return nil, oops.Wrap(err)
+	}
+	return &existing, nil
+}
+
+func (s *IdempotencyStore) Complete(ctx context.Context, record base.IdempotencyRecord) error {
+	// 按主键 Key 更新
+	err := s.tx.WithContext(ctx).Table(s.table).Select("status", "header", "body").Updates(&record).Error
+	if err != nil {
+		return oops.Wrap(err)
+	}
+	return nil
+}
+
+func (s *IdempotencyStore) Release(ctx context.Context, key string) error {
+	err := s.tx.WithContext(ctx).Table(s.table).Where(clause.Eq{Column: idempotencyKeyColumn, Value: key}).Where("status = 0").Delete(&base.IdempotencyRecord{}).Error
+	if err != nil {
+		return oops.Wrap(err)
+	}
+	return nil
+}
@@ -0,0 +1,146 @@
+package db
+
+import (
+	"context"
+	"reflect"
+	"sgo-api/base"
+	"strings"
+
+	"github.com/samber/oops"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	// 使用 ! 作为 LIKE 的转义字符：SQLite 等没有默认的转义字符，需要 ESCAPE 指定；
+	// 反斜杠在 MySQL 的字符串字面量中本身需要转义，在 PostgreSQL 中不需要，无法写出通用的 SQL
+	likeReplacer = strings.NewReplacer(`!`, `!!`, `%`, `!%`, `_`, `!_`)
+)
+
+// 按分页规则查询列表，排序和过滤只使用规则中的列名，并通过 clause 引用，不会拼接用户输入
+//
+//   - 使用页码分页时统计总数（除非 SkipTotal），使用游标分页时多查一条判断是否有下一页
+//
+//   - tx 可以预先设置 Where、Joins 等条件，以及 WithContext
+//
+//     page, err := db.Paginate[User](tx.WithContext(ctx), q, rule)
+func Paginate[T any](tx *gorm.DB, q base.PageQuery, rule base.PageRule) (base.Page[T], error) {
+	page := base.Page[T]{Items: []T{}, Total: -1}
+
+	q, err := rule.Normalize(q)
+	if err != nil {
+		return page, oops.Wrap(err)
+	}
+	page.Page = q.Page
+	page.PageSize = q.PageSize
+
+	if tx.Statement.Model == nil && tx.Statement.Table == "" {
+		tx = tx.Model(new(T))
+	}
+
+	exprs := []clause.Expression{}
+	for _, f := range q.Filters {
+		exprs = append(exprs, filterExpr(rule.Filters[f.Field].Column, f))
+	}
+	if len(exprs) > 0 {
+		tx = tx.Clauses(clause.Where{Exprs: exprs})
+	}
+	tx = tx.Session(&gorm.Session{})
+
+	if !rule.SkipTotal {
+		if err := tx.Count(&page.Total).Error; err != nil {
+			return page, oops.Wrap(err)
+		}
+	}
+
+	query := tx
+	for _, s := range q.Sorts {
+		query = query.Order(clause.OrderByColumn{Column: clause.Column{Name: rule.Sorts[s.Field]}, Desc: s.Desc})
+	}
+
+	if rule.CursorField == "" {
+		if err := query.Offset((q.Page - 1) * q.PageSize).Limit(q.PageSize).Find(&page.Items).Error; err != nil {
+			return page, oops.Wrap(err)
+		}
+		return page, nil
+	}
+
+	column := rule.Sorts[rule.CursorField]
+	if q.Cursor != "" {
+		value, err := base.DecodeCursor(q.Cursor)
+		if err != nil {
+			return page, oops.Wrap(err)
+		}
+
+		var expr clause.Expression = clause.Gt{Column: clause.Column{Name: column}, Value: value}
+		if rule.CursorDesc {
+			expr = clause.Lt{Column: clause.Column{Name: column}, Value: value}
+		}
+		query = query.Clauses(clause.Where{Exprs: []clause.Expression{expr}})
+	}
+
+	if err := query.Limit(q.PageSize + 1).Find(&page.Items).Error; err != nil {
+		return page, oops.Wrap(err)
+	}
+	if len(page.Items) > q.PageSize {
+		page.Items = page.Items[:q.PageSize]
+
+		cursor, err := cursorOf(tx, column, page.Items[len(page.Items)-1])
+		if err != nil {
+			return page, oops.Wrap(err)
+		}
+		page.NextCursor = cursor
+	}
+
+	return page, nil
+}
+
+func filterExpr(column string, f base.Filter) clause.Expression {
+	col := clause.Column{Name: column}
+
+	switch f.Op {
+	case base.FilterNe:
+		return clause.Neq{Column: col, Value: f.Values[0]}
+	case base.FilterGt:
+		return clause.Gt{Column: col, Value: f.Values[0]}
+	case base.FilterGte:
+		return clause.Gte{Column: col, Value: f.Values[0]}
+	case base.FilterLt:
+		return clause.Lt{Column: col, Value: f.Values[0]}
+	case base.FilterLte:
+		return clause.Lte{Column: col, Value: f.Values[0]}
+	case base.FilterLike:
+		// 按包含匹配，转义用户输入中的通配符
+		return clause.Expr{SQL: "? LIKE ? ESCAPE '!'", Vars: []any{col, "%" + likeReplacer.Replace(f.Values[0]) + "%"}}
+	case base.FilterIn:
+		values := []any{}
+		for _, v := range f.Values {
+			values = append(values, v)
+		}
+		return clause.IN{Column: col, Values: values}
+	default:
+		return clause.Eq{Column: col, Value: f.Values[0]}
+	}
+}
+
+// 从最后一条记录中取出游标字段的值
+func cursorOf[T any](tx *gorm.DB, column string, item T) (string, error) {
+	stmt := &gorm.Statement{DB: tx}
+	if err := stmt.Parse(new(T)); err != nil {
+		return "", oops.Wrapf(err, "解析模型失败")
+	}
+
+	// 列名可能带表名，比如 users.id
+	name := column[strings.LastIndex(column, ".")+1:]
+	field := stmt.Schema.LookUpField(name)
+	if field == nil {
+		return "", oops.Errorf("模型中没有游标列 %v", column)
+	}
+
+	ctx := tx.Statement.Context
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	value, _ := field.ValueOf(ctx, reflect.ValueOf(&item).Elem())
+	return base.EncodeCursor(value)
+}
@@ -0,0 +1,73 @@
+package db
+
+import (
+	"context"
+	"time"
+
+	"github.com/samber/oops"
+	"gorm.io/gorm"
+)
+
+const (
+	timeoutCancelKey = "sgo-api:timeout_cancel"
+)
+
+// 给没有截止时间的 SQL 设置默认超时。context 已有截止时间时（比如经过 api.TimeoutMiddleware），沿用原有的截止时间
+// Row、Rows 在回调结束后才读取结果，无法在回调中取消，不设置超时，需要时由调用方通过 WithContext 设置
+//
+//	db.Use(TimeoutPlugin{Default: 10 * time.Second})
+type TimeoutPlugin struct {
+	Default time.Duration
+}
+
+func (p TimeoutPlugin) Name() string {
+	return "sgo-api:timeout"
+}
+
+func (p TimeoutPlugin) Initialize(db *gorm.DB) error {
+	if p.Default <= 0 {
+		return nil
+	}
+
+	before := func(tx *gorm.DB) {
+		ctx := tx.Statement.Context
+		if ctx == nil {
+			ctx = context.Background()
+		}
+		if _, ok := ctx.Deadline(); ok {
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(ctx, p.Default)
+		tx.Statement.Context = ctx
+		tx.InstanceSet(timeoutCancelKey, cancel)
+	}
+
+	after := func(tx *gorm.DB) {
+		if v, ok := tx.InstanceGet(timeoutCancelKey); ok {
+			if cancel, ok := v.(context.CancelFunc); ok {
+				cancel()
+			}
+		}
+	}
+
+	cb := db.Callback()
+	for _, err := range []error{
+		cb.Create().Before("*").Register("sgo-api:timeout_before_create", before),
+		cb.Create().After("*").Register("sgo-api:timeout_after_create", after),
+		cb.Query().Before("*").Register("sgo-api:timeout_before_query", before),
+		cb.Query().After("*").Register("sgo-api:timeout_after_query", after),
+		cb.Update().Before("*").Register("sgo-api:timeout_before_update", before),
+		cb.Update().After("*").Register("sgo-api:timeout_after_update", after),
+		cb.Delete().Before("*").Register("sgo-api:timeout_before_delete", before),
+		cb.Delete().After("*").Register("sgo-api:timeout_after_delete", after),
+		cb.Raw().Before("*").Register("sgo-api:timeout_before_raw", before),
+		cb.Raw().After("*").Register("sgo-api:timeout_after_raw", after),
+	} {
+		if err != nil {
+			return oops.Wrap(err)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,364 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"iter"
+	"sgo-api/base"
+	"sync"
+	"time"
+
+	"github.com/samber/oops"
+)
+
+var errPipelineStopped = errors.New("流水线已停止")
+
+// 流式流水线，适用于分页扫描、大文件等无法一次性拿到所有元素的场景
+//   - 各阶段之间通过有界通道连接，下游处理不过来时上游会阻塞（背压）
+//   - 任一阶段出错或恐慌时，会取消整个流水线
+//   - 所有阶段创建完成后，需调用 Wait 等待结束并获取错误
+type Pipeline struct {
+	ctx    context.Context
+	cancel context.CancelCauseFunc
+
+	wg sync.WaitGroup
+
+	lock sync.Mutex
+	errs []error
+}
+
+func New(ctx context.Context) *Pipeline {
+	ctx, cancel := context.WithCancelCause(ctx)
+
+	return &Pipeline{
+		ctx:    ctx,
+		cancel: cancel,
+	}
+}
+
+// 流水线的 context，流水线出错或停止时结束
+func (p *Pipeline) Context() context.Context {
+	return p.ctx
+}
+
+// 主动停止流水线，不视为错误
+func (p *Pipeline) Stop() {
+	p.cancel(errPipelineStopped)
+}
+
+// 等待所有阶段结束，返回各阶段的错误
+func (p *Pipeline) Wait() error {
+	p.wg.Wait()
+
+	cause := context.Cause(p.ctx)
+	p.cancel(nil)
+
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	if len(p.errs) > 0 {
+		return oops.Wrap(base.NewMultiError(p.errs...))
+	}
+	if cause != nil && !errors.Is(cause, errPipelineStopped) {
+		return oops.Wrap(cause)
+	}
+	return nil
+}
+
+func (p *Pipeline) fail(err error) {
+	p.lock.Lock()
+	p.errs = append(p.errs, err)
+	p.lock.Unlock()
+
+	p.cancel(err)
+}
+
+func (p *Pipeline) goStage(name string, n int, f func(), after func()) {
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		p.wg.Add(1)
+
+		go func() {
+			base.Try(f).Catch(func(err error) {
+				p.fail(oops.Wrapf(err, "%v 阶段恐慌", name))
+			}).Finally(func() {
+				wg.Done()
+				p.wg.Done()
+			}).Do()
+		}()
+	}
+
+	if after != nil {
+		p.wg.Add(1)
+
+		go func() {
+			defer p.wg.Done()
+
+			wg.Wait()
+			after()
+		}()
+	}
+}
+
+// 将迭代器作为流水线的源头
+func FromSeq[T any](p *Pipeline, seq iter.Seq[T]) <-chan T {
+	out := make(chan T)
+
+	p.goStage("FromSeq", 1, func() {
+		for v := range seq {
+			if !send(p.ctx, out, v) {
+				return
+			}
+		}
+	}, func() {
+		close(out)
+	})
+
+	return out
+}
+
+// 将流水线的输出转为迭代器，提前结束迭代会停止流水线
+func ToSeq[T any](p *Pipeline, in <-chan T) iter.Seq[T] {
+	return func(yield func(T) bool) {
+		for {
+			v, ok := recv(p.ctx, in)
+			if !ok {
+				return
+			}
+
+			if !yield(v) {
+				p.Stop()
+				return
+			}
+		}
+	}
+}
+
+// 并发映射，concurrent 为并发数量，输出顺序不保证与输入一致
+func Map[I any, O any](p *Pipeline, in <-chan I, concurrent int, f func(ctx context.Context, item I) (O, error)) <-chan O {
+	if concurrent <= 0 {
+		concurrent = 1
+	}
+
+	out := make(chan O, concurrent)
+
+	p.goStage("Map", concurrent, func() {
+		for {
+			v, ok := recv(p.ctx, in)
+			if !ok {
+				return
+			}
+
+			o, err := f(p.ctx, v)
+			if err != nil {
+				p.fail(oops.Wrap(err))
+				return
+			}
+
+			if !send(p.ctx, out, o) {
+				return
+			}
+		}
+	}, func() {
+		close(out)
+	})
+
+	return out
+}
+
+// 并发过滤，保留 f 返回 true 的元素，输出顺序不保证与输入一致
+func Filter[T any](p *Pipeline, in <-chan T, concurrent int, f func(ctx context.Context, item T) (bool, error)) <-chan T {
+	if concurrent <= 0 {
+		concurrent = 1
+	}
+
+	out := make(chan T, concurrent)
+
+	p.goStage("Filter", concurrent, func() {
+		for {
+			v, ok := recv(p.ctx, in)
+			if !ok {
+				return
+			}
+
+			keep, err := f(p.ctx, v)
+			if err != nil {
+				p.fail(oops.Wrap(err))
+				return
+			}
+
+			if keep && !send(p.ctx, out, v) {
+				return
+			}
+		}
+	}, func() {
+		close(out)
+	})
+
+	return out
+}
+
+// 分批，凑够 size 个元素或距离上次输出超过 interval 时输出一批。interval 为 0 时只按数量分批
+func Batch[T any](p *Pipeline, in <-chan T, size int, interval time.Duration) <-chan []T {
+	if size <= 0 {
+		size = 1
+	}
+
+	out := make(chan []T)
+
+	p.goStage("Batch", 1, func() {
+		var tick <-chan time.Time
+		if interval > 0 {
+			ticker := time.NewTicker(interval)
+			defer ticker.Stop()
+			tick = ticker.C
+		}
+
+		batch := make([]T, 0, size)
+		flush := func() bool {
+			if len(batch) == 0 {
+				return true
+			}
+
+			ok := send(p.ctx, out, batch)
+			batch = make([]T, 0, size)
+			return ok
+		}
+
+		for {
+			select {
+			case v, ok := <-in:
+				if !ok {
+					flush()
+					return
+				}
+
+				batch = append(batch, v)
+				if len(batch) >= size && !flush() {
+					return
+				}
+			case <-tick:
+				if !flush() {
+					return
+				}
+			case <-p.ctx.Done():
+				return
+			}
+		}
+	}, func() {
+		close(out)
+	})
+
+	return out
+}
+
+// 扇出，将元素分发到 n 个输出通道，每个元素只会进入其中一个（哪个先就绪进哪个）
+func FanOut[T any](p *Pipeline, in <-chan T, n int) []<-chan T {
+	if n <= 0 {
+		n = 1
+	}
+
+	outs := make([]chan T, n)
+	ros := make([]<-chan T, n)
+	for i := range outs {
+		outs[i] = make(chan T)
+		ros[i] = outs[i]
+	}
+
+	for i := range outs {
+		out := outs[i]
+
+		p.goStage("FanOut", 1, func() {
+			for {
+				v, ok := recv(p.ctx, in)
+				if !ok {
+					return
+				}
+
+				if !send(p.ctx, out, v) {
+					return
+				}
+			}
+		}, func() {
+			close(out)
+		})
+	}
+
+	return ros
+}
+
+// 扇入，将多个通道合并为一个
+func FanIn[T any](p *Pipeline, ins ...<-chan T) <-chan T {
+	out := make(chan T, len(ins))
+
+	var wg sync.WaitGroup
+	for _, in := range ins {
+		wg.Add(1)
+
+		p.goStage("FanIn", 1, func() {
+			defer wg.Done()
+
+			for {
+				v, ok := recv(p.ctx, in)
+				if !ok {
+					return
+				}
+
+				if !send(p.ctx, out, v) {
+					return
+				}
+			}
+		}, nil)
+	}
+
+	p.wg.Add(1)
+	go func() {
+		defer p.wg.Done()
+
+		wg.Wait()
+		close(out)
+	}()
+
+	return out
+}
+
+// 并发消费，作为流水线的终点
+func ForEach[T any](p *Pipeline, in <-chan T, concurrent int, f func(ctx context.Context, item T) error) {
+	if concurrent <= 0 {
+		concurrent = 1
+	}
+
+	p.goStage("ForEach", concurrent, func() {
+		for {
+			v, ok := recv(p.ctx, in)
+			if !ok {
+				return
+			}
+
+			if err := f(p.ctx, v); err != nil {
+				p.fail(oops.Wrap(err))
+				return
+			}
+		}
+	}, nil)
+}
+
+func send[T any](ctx context.Context, ch chan<- T, v T) bool {
+	select {
+	case ch <- v:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+func recv[T any](ctx context.Context, ch <-chan T) (T, bool) {
+	select {
+	case v, ok := <-ch:
+		return v, ok
+	case <-ctx.Done():
+		var v T
+		return v, false
+	}
+}